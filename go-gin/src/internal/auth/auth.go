@@ -1,13 +1,24 @@
 package auth
 
-import "github.com/gin-gonic/gin"
-
-func Not_req_from_simulator(c *gin.Context) (statusCode int, errStr string) {
-	auth := c.Request.Header.Get("Authorization")
-	if auth != "Basic c2ltdWxhdG9yOnN1cGVyX3NhZmUh" {
-		statusCode = 403
-		errStr = "You are not authorized to use this resource!"
-		return statusCode, errStr
+import (
+	"go-minitwit-core/src/apiauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey holds the apiauth.Principal of an authenticated API request
+const PrincipalKey = "apiClient"
+
+// Simulator authenticates an API request as one of the simulator clients,
+// the error is one of apiauth's. A request is only checked once.
+func Simulator(c *gin.Context, clients *apiauth.Authenticator) error {
+	if _, ok := c.Get(PrincipalKey); ok {
+		return nil
+	}
+	principal, err := clients.Authenticate(c.Request.Header.Get("Authorization"))
+	if err != nil {
+		return err
 	}
-	return
+	c.Set(PrincipalKey, principal)
+	return nil
 }
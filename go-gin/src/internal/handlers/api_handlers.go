@@ -4,54 +4,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"go-gin/src/internal/auth"
-	"go-gin/src/internal/config"
-	"go-gin/src/internal/db"
-	"go-gin/src/internal/helpers"
+	"go-minitwit-core/src/apierror"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/models"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-type UserData struct {
-	Username string
-	Email    string
-	Pwd      string
-}
-
-type MessageData struct {
-	Content string `json:"content"`
-}
-
-func UpdateLatestHandler(c *gin.Context) {
-	parsedCommandID := c.Query("latest")
-	commandID, err := strconv.Atoi(parsedCommandID)
-
-	if err != nil || parsedCommandID == "" {
-		// Handle the case where the parameter is not present or cannot be converted to an integer
-		commandID = -1
-	}
-	if commandID != -1 {
-		err := db.UpdateLatest(commandID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update latest value"})
-			return
-		}
-	}
-}
-
-func GetLatestHandler(c *gin.Context) {
-	latestProcessedCommandID, err := db.GetLatest()
+func (h *Handler) GetLatestHandler(c *gin.Context) {
+	latestProcessedCommandID, err := h.Store.GetLatest()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read latest value"})
+		abortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"latest": latestProcessedCommandID})
 }
 
-func GetLatestHelper() int {
-	latestProcessedCommandID, err := db.GetLatest()
+func (h *Handler) GetLatestHelper() int {
+	latestProcessedCommandID, err := h.Store.GetLatest()
 	if err != nil {
 		return -2
 	}
@@ -62,50 +34,27 @@ func GetLatestHelper() int {
 /api/register
 POST
 Takes data from the POST and registers a user in the db
-returns: ("", 204) or ({"status": 400, "error_msg": error}, 400)
+returns: ("", 204) or ({"status": 400, "error_msg": error, "code": code}, 400)
 */
-func ApiRegisterHandler(c *gin.Context) {
-	//Update latest
-	UpdateLatestHandler(c)
-
-	not_req_from_sim_statusCode, not_req_from_sim_errStr := auth.Not_req_from_simulator(c)
-	if not_req_from_sim_statusCode == 403 && not_req_from_sim_errStr != "" {
-		fmt.Println("Request denied: not from simulator")
-		c.AbortWithStatusJSON(http.StatusForbidden, "Request denied: not from simulator")
+func (h *Handler) ApiRegisterHandler(c *gin.Context) {
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	// Check if user already exists
-	//TODO: DO IT
-
 	// Read the request body
-	var registerReq UserData
+	var registerReq models.RegisterData
 	err := json.NewDecoder(c.Request.Body).Decode(&registerReq)
 	if err != nil {
-		fmt.Println("Error failed to read request body")
-		c.AbortWithStatusJSON(400, "Error failed to read request body")
-		return
-	}
-
-	// Get user ID
-	user, err := db.GetUserByUsername(registerReq.Username)
-	if err == nil && user.Username != "" {
-		fmt.Println("Error getting username by id")
-		c.AbortWithStatusJSON(400, "Error getting username by id")
+		abortWithError(c, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
 		return
 	}
 
 	if c.Request.Method == http.MethodPost {
-		err := db.RegisterUser(registerReq.Username, registerReq.Email, registerReq.Pwd)
-		if err != nil {
-			fmt.Println("Failed registration attempt due to an error during registration")
-			c.AbortWithStatusJSON(400, "Failed to register user")
+		if err := h.Service.Register(registerReq.Username, registerReq.Email, registerReq.Pwd); err != nil {
+			abortWithError(c, err)
 			return
 		}
-		if config.DB.Error != nil {
-			fmt.Println("Registration error: ", config.DB.Error)
-			c.AbortWithStatusJSON(http.StatusBadRequest, "Registration error: ")
-		}
 	}
 
 	c.JSON(204, "")
@@ -115,24 +64,29 @@ func ApiRegisterHandler(c *gin.Context) {
 /api/msgs
 /api/msgs?no=<num>
 */
-func ApiMsgsHandler(c *gin.Context) {
-	UpdateLatestHandler(c)
-
-	not_req_from_sim_statusCode, not_req_from_sim_errStr := auth.Not_req_from_simulator(c)
-	if not_req_from_sim_statusCode == 403 && not_req_from_sim_errStr != "" {
-		fmt.Println("Request denied: not from simulator")
-		c.AbortWithStatusJSON(http.StatusForbidden, "Request denied: not from simulator")
+func (h *Handler) ApiMsgsHandler(c *gin.Context) {
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
 	if c.Request.Method == http.MethodGet {
-		messages, err := db.GetPublicMessages(100)
+		page, ok := h.apiPageFromQuery(c)
+		if !ok {
+			return
+		}
+		format, ok := dateFormat(c)
+		if !ok {
+			return
+		}
+		timeline, err := h.Service.PublicTimeline(page)
 		if err != nil {
-			fmt.Println("Failed to fetch messages from DB")
-			c.AbortWithStatusJSON(http.StatusBadRequest, "Failed to fetch messages from DB")
+			abortWithError(c, err)
+			return
 		}
 
-		filteredMessages := helpers.FilterMessages(messages)
+		setLinkHeader(c, timeline)
+		filteredMessages := helpers.FilterMessages(timeline.Messages, format)
 		jsonFilteredMessages, _ := json.Marshal(filteredMessages)
 		c.Header("Content-Type", "application/json")
 		c.String(http.StatusOK, string(jsonFilteredMessages))
@@ -141,69 +95,65 @@ func ApiMsgsHandler(c *gin.Context) {
 
 /*
 /api/msgs/<username>
+/api/msgs/<username>?no=<num>
 */
-func ApiMsgsPerUserHandler(c *gin.Context) {
-	UpdateLatestHandler(c)
-
-	not_req_from_sim_statusCode, not_req_from_sim_errStr := auth.Not_req_from_simulator(c)
-	if not_req_from_sim_statusCode == 403 && not_req_from_sim_errStr != "" {
-		fmt.Println("Request denied: not from simulator")
-		c.AbortWithStatusJSON(http.StatusForbidden, "Request denied: not from simulator")
+func (h *Handler) ApiMsgsPerUserHandler(c *gin.Context) {
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
 	profileUserName := c.Param("username")
-	userId, err := db.GetUserIDByUsername(profileUserName)
-	if userId == -1 {
-		c.AbortWithStatus(http.StatusNotFound)
-		return
-	}
-	if err != nil || userId == -1 {
-		if errAbort := c.AbortWithError(http.StatusInternalServerError, err); errAbort != nil {
-			fmt.Printf("Failed to abort with error: %v", errAbort)
-		}
+	userId, err := h.Store.GetUserIDByUsername(profileUserName)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
 	if c.Request.Method == http.MethodGet {
-		messages, err := db.GetUserMessages(userId, 100)
+		page, ok := h.apiPageFromQuery(c)
+		if !ok {
+			return
+		}
+		format, ok := dateFormat(c)
+		if !ok {
+			return
+		}
+		timeline, err := h.Service.UserMessages(userId, page)
 		if err != nil {
-			fmt.Println("Failed to fetch messages from DB")
-			c.AbortWithStatusJSON(http.StatusInternalServerError, "Failed to fetch messages from DB")
+			abortWithError(c, err)
+			return
 		}
 
-		filteredMessages := helpers.FilterMessages(messages)
+		setLinkHeader(c, timeline)
+		filteredMessages := helpers.FilterMessages(timeline.Messages, format)
 		jsonFilteredMessages, _ := json.Marshal(filteredMessages)
 		c.Header("Content-Type", "application/json")
 		c.String(http.StatusOK, string(jsonFilteredMessages))
 
 	} else if c.Request.Method == http.MethodPost {
-		var messageReq MessageData
+		var messageReq models.MessageData
 
 		err := json.NewDecoder(c.Request.Body).Decode(&messageReq)
 		if err != nil {
-			fmt.Println("Failed to read request body")
-			c.AbortWithStatusJSON(http.StatusBadRequest, "Failed to read request body")
+			abortWithError(c, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
 			return
 		}
 
-		err = db.AddMessage(messageReq.Content, userId)
+		err = h.Store.AddMessage(messageReq.Content, userId)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, "Failed to upload message")
+			abortWithError(c, err)
+			return
 		}
 
 		c.JSON(http.StatusNoContent, "")
 	}
 }
 
-func ApiFllwsHandler(c *gin.Context) {
-	//Update latest value
-	UpdateLatestHandler(c)
-
+func (h *Handler) ApiFllwsHandler(c *gin.Context) {
 	//Ensure authentication
-	not_req_from_sim_statusCode, not_req_from_sim_errStr := auth.Not_req_from_simulator(c)
-	if not_req_from_sim_statusCode == 403 && not_req_from_sim_errStr != "" {
-		fmt.Println("Request denied: not from simulator")
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
@@ -211,45 +161,34 @@ func ApiFllwsHandler(c *gin.Context) {
 	profileUserName := c.Param("username")
 
 	//Get userID
-	userId, err := db.GetUserIDByUsername(profileUserName)
-	if err != nil || userId == -1 {
-		fmt.Println("Failed to get user ID for follow/unfollow actions")
-		c.AbortWithStatus(http.StatusNotFound)
+	userId, err := h.Store.GetUserIDByUsername(profileUserName)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
 	//Set follow-request type
-	var requestBody struct {
-		Follow   string `json:"follow"`
-		Unfollow string `json:"unfollow"`
-	}
+	var requestBody models.FollowData
 
 	if c.Request.Method == http.MethodPost {
 
 		// Decode JSON body for POST requests
 		if err := json.NewDecoder(c.Request.Body).Decode(&requestBody); err != nil {
-			c.AbortWithStatusJSON(http.StatusNotFound, "Failed decoding request")
+			abortWithError(c, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
 			return
 		}
 
 		// Check if neither follow nor unfollow is provided
 		if requestBody.Follow == "" && requestBody.Unfollow == "" {
-			fmt.Println("Missing follow/unfollow field in request body")
-			c.AbortWithStatusJSON(http.StatusNotFound, "Missing follow/unfollow field in request body")
+			abortWithError(c, fmt.Errorf("%w: neither follow nor unfollow is set", apierror.ErrMalformedBody))
 			return
 		}
 
 		if requestBody.Follow != "" {
-			follow_user_id, err := db.GetUserIDByUsername(requestBody.Follow)
-			if err != nil || follow_user_id == -1 {
-				fmt.Println("Failed to get user ID for follow/unfollow actions")
-				c.AbortWithStatus(http.StatusNotFound)
-				return
-			}
-
 			// Follow the user
-			if err := db.FollowUser(userId, follow_user_id); err != nil {
-				c.AbortWithStatusJSON(http.StatusNotFound, "Failed to follow user")
+			err := h.Service.Follow(userId, requestBody.Follow)
+			if err != nil {
+				abortWithError(c, err)
 				return
 			}
 
@@ -257,15 +196,10 @@ func ApiFllwsHandler(c *gin.Context) {
 			return
 
 		} else if requestBody.Unfollow != "" {
-			unfollow_user_id, err := db.GetUserIDByUsername(requestBody.Unfollow)
-			if err != nil || unfollow_user_id == -1 {
-				c.AbortWithStatus(http.StatusNotFound)
-				return
-			}
-
 			// Unfollow the user
-			if err := db.UnfollowUser(userId, unfollow_user_id); err != nil {
-				c.AbortWithStatusJSON(http.StatusNotFound, "Failed to unfollow user")
+			err := h.Service.Unfollow(userId, requestBody.Unfollow)
+			if err != nil {
+				abortWithError(c, err)
 				return
 			}
 
@@ -273,10 +207,14 @@ func ApiFllwsHandler(c *gin.Context) {
 		}
 
 	} else if c.Request.Method == http.MethodGet {
-		followers, err := db.GetFollowing(userId, 100)
+		limit, ok := h.apiLimit(c)
+		if !ok {
+			return
+		}
+		followers, err := h.Store.GetFollowing(userId, limit)
 		if err != nil {
-			fmt.Println("Failed to fetch followers from DB")
-			c.AbortWithStatusJSON(http.StatusInternalServerError, "Failed to fetch followers from DB")
+			abortWithError(c, err)
+			return
 		}
 
 		// empty slice for follower usernames
@@ -295,3 +233,38 @@ func ApiFllwsHandler(c *gin.Context) {
 		c.JSON(200, followersResponse)
 	}
 }
+
+/*
+/api/delete
+POST
+Deletes a message of the user ({"user": <username>, "pwd": <password>, "message_id": <message_id>})
+or, without a message_id, the user together with their messages and follower relations.
+The password has to be the user's, as for deleting the account in the UI
+returns: ("", 204)
+*/
+func (h *Handler) ApiDeleteHandler(c *gin.Context) {
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var deleteReq models.DeleteData
+	if err := json.NewDecoder(c.Request.Body).Decode(&deleteReq); err != nil {
+		abortWithError(c, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
+		return
+	}
+	if deleteReq.User == "" {
+		abortWithError(c, fmt.Errorf("%w: user is not set", apierror.ErrMalformedBody))
+		return
+	}
+	if deleteReq.MessageID < 0 {
+		abortWithError(c, apierror.ErrInvalidMessageID)
+		return
+	}
+
+	if err := h.Service.DeleteOwn(deleteReq.User, deleteReq.Pwd, deleteReq.MessageID); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, "")
+}
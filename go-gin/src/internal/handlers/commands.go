@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"go-minitwit-core/src/commandlog"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// commandWriter keeps a copy of the response body for the command log
+type commandWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *commandWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *commandWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// CommandLogHandler runs the simulator's commands, the API requests with a
+// ?latest= id, through the command log: a retried id is answered with the
+// recorded response and only successful commands move latest on. Reads are
+// not recorded, they run every time. Requests that are not from a simulator
+// client are left to the handlers to reject, so they cannot take an id away
+// from the simulator.
+func (h *Handler) CommandLogHandler(c *gin.Context) {
+	id, ok := commandlog.ID(c.Request.URL.Query())
+	if !ok {
+		return
+	}
+	if _, err := h.Clients.Authenticate(c.GetHeader("Authorization")); err != nil {
+		return
+	}
+	if !commandlog.Mutating(c.Request.Method) {
+		c.Next()
+		if err := h.Commands.Read(id, c.Writer.Status()); err != nil {
+			log.Printf("%s %s: moving latest to %d: %v", c.Request.Method, c.Request.URL.Path, id, err)
+		}
+		return
+	}
+
+	claim, run, err := h.Commands.Begin(id, c.Request.Method, c.Request.URL.Path)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !run {
+		commandlog.Replay(c.Writer, claim)
+		c.Abort()
+		return
+	}
+
+	w := &commandWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+
+	// the response is out already, a failure only costs the replay
+	if err := h.Commands.Finish(claim, w.Status(), w.Header().Get("Content-Type"), w.body.Bytes()); err != nil {
+		log.Printf("%s %s: recording command %d: %v", c.Request.Method, c.Request.URL.Path, id, err)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"go-gin/src/internal/auth"
+	"go-minitwit-core/src/energy"
+	"go-minitwit-core/src/metrics"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnergyHandler accounts the CPU time and energy of every request to its
+// route, labelled like MetricsHandler, and the handler that answered it
+func (h *Handler) EnergyHandler(c *gin.Context) {
+	done := h.Energy.Begin()
+	c.Next()
+	done(c.Request.Method, metrics.TemplateRoute(c.FullPath()), energy.HandlerName(c.HandlerName()))
+}
+
+// EnergyReportHandler serves the accounting per route. Like the reset it is
+// for the simulator clients only, the routes and timings are the app's profile.
+func (h *Handler) EnergyReportHandler(c *gin.Context) {
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.Energy.Report())
+}
+
+// EnergyResetHandler starts the accounting over, e.g. before a measured run.
+// Only the simulator clients may, anyone else could wipe a run.
+func (h *Handler) EnergyResetHandler(c *gin.Context) {
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	h.Energy.Reset()
+	c.Status(http.StatusNoContent)
+}
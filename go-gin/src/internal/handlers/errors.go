@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/apierror"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logInternal logs err if it is not the client's fault, the response only
+// says that something went wrong
+func logInternal(c *gin.Context, err error) {
+	if apierror.Status(err) == http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+}
+
+// errorStatus is the status to answer err with, the failures that are not the
+// client's fault are logged
+func errorStatus(c *gin.Context, err error) int {
+	logInternal(c, err)
+	return apierror.Status(err)
+}
+
+// abortWithError aborts an API request with the JSON error body of err
+func abortWithError(c *gin.Context, err error) {
+	logInternal(c, err)
+	apierror.Write(c.Writer, err)
+	c.Abort()
+}
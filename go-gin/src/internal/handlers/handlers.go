@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/commandlog"
+	"go-minitwit-core/src/config"
+	"go-minitwit-core/src/energy"
+	"go-minitwit-core/src/openapi"
+	"go-minitwit-core/src/password"
+	"go-minitwit-core/src/service"
+	"go-minitwit-core/src/store"
+)
+
+// Handler carries the dependencies shared by all route handlers
+type Handler struct {
+	Store   store.Store
+	Service *service.Service
+	// Clients are the API credentials accepted on /api
+	Clients *apiauth.Authenticator
+	// Limits are the page sizes, New sets the reference defaults
+	Limits config.Limits
+	// Commands records the simulator's commands, see CommandLogHandler
+	Commands *commandlog.Log
+	// OpenAPI rejects API requests that do not match the document, see ValidateHandler
+	OpenAPI *openapi.Validator
+	// Energy accounts CPU time and energy per route, nil turns it off
+	Energy *energy.Meter
+}
+
+func New(s store.Store, passwords *password.Policy, clients *apiauth.Authenticator) *Handler {
+	return &Handler{
+		Store:    s,
+		Service:  service.New(s, passwords),
+		Clients:  clients,
+		Limits:   config.DefaultLimits,
+		Commands: commandlog.New(s),
+		OpenAPI:  openapi.Default(),
+	}
+}
@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/memstore"
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/password"
+	"go-minitwit-core/src/store"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+const simulatorAuth = "Basic c2ltdWxhdG9yOnN1cGVyX3NhZmUh"
+
+func newTestRouter(t *testing.T) (*gin.Engine, *memstore.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := memstore.New()
+	h := New(s, password.NewPolicy(password.Bcrypt{Cost: 4}), apiauth.Default())
+	// TestModeration registers mod first
+	h.Service.SetModerators([]int{1})
+
+	r := gin.New()
+	r.LoadHTMLGlob("../../../templates/*.html")
+	r.Use(sessions.Sessions("session", cookie.NewStore([]byte("test"))))
+	r.Use(h.ValidateHandler, h.CommandLogHandler)
+
+	r.GET("/", h.MyTimelineHandler)
+	r.POST("/login", h.LoginHandler)
+	r.GET("/api/msgs", h.ApiMsgsHandler)
+	r.GET("/api/fllws/:username", h.ApiFllwsHandler)
+	r.POST("/api/fllws/:username", h.ApiFllwsHandler)
+	r.POST("/api/msgs/:username", h.ApiMsgsPerUserHandler)
+	r.GET("/flagged", h.FlaggedHandler)
+	r.POST("/unflag/:message_id", h.FlagMessageHandler)
+	r.GET("/api/flagged", h.ApiFlaggedHandler)
+	r.PUT("/api/flagged/:message_id", h.ApiFlagHandler)
+	r.POST("/api/delete", h.ApiDeleteHandler)
+	r.POST("/delete_message/:message_id", h.DeleteMessageHandler)
+	r.POST("/delete_account", h.DeleteAccountHandler)
+	return r, s
+}
+
+func serve(r http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// addLastCookies copies the response cookies onto req; the handlers save the
+// session more than once per request, so only the last value of each cookie counts.
+func addLastCookies(req *http.Request, w *httptest.ResponseRecorder) {
+	last := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		last[c.Name] = c
+	}
+	for _, c := range last {
+		req.AddCookie(c)
+	}
+}
+
+func TestApiMsgsHandler(t *testing.T) {
+	r, s := newTestRouter(t)
+	_ = s.RegisterUser("aa", "a@a.a", "a")
+	_ = s.AddMessage("Blub!", 1)
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/api/msgs", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("without Authorization: status = %d, want 403", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/msgs?latest=4", nil)
+	req.Header.Set("Authorization", simulatorAuth)
+	w = serve(r, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	var msgs []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &msgs); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if len(msgs) != 1 || msgs[0]["content"] != "Blub!" || msgs[0]["user"] != "aa" {
+		t.Errorf("messages = %v", msgs)
+	}
+	if latest, _ := s.GetLatest(); latest != 4 {
+		t.Errorf("latest = %d, want 4", latest)
+	}
+}
+
+func TestApiFllwsHandler(t *testing.T) {
+	r, s := newTestRouter(t)
+	for _, name := range []string{"aa", "bb"} {
+		_ = s.RegisterUser(name, name+"@x.y", "p")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/fllws/aa", strings.NewReader(`{"follow": "bb"}`))
+	req.Header.Set("Authorization", simulatorAuth)
+	if w := serve(r, req); w.Code != http.StatusNoContent {
+		t.Fatalf("follow: status = %d, want 204", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/fllws/aa", strings.NewReader(`{"follow": "nobody"}`))
+	req.Header.Set("Authorization", simulatorAuth)
+	if w := serve(r, req); w.Code != http.StatusNotFound {
+		t.Errorf("follow unknown user: status = %d, want 404", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/fllws/aa", nil)
+	req.Header.Set("Authorization", simulatorAuth)
+	w := serve(r, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var body struct {
+		Follows []string `json:"follows"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Follows) != 1 || body.Follows[0] != "bb" {
+		t.Errorf("follows = %v, want [bb]", body.Follows)
+	}
+}
+
+func TestMyTimelineHandler(t *testing.T) {
+	r, s := newTestRouter(t)
+	_ = s.RegisterUser("aa", "a@a.a", "secret")
+	_ = s.AddMessage("hello from aa", 1)
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+		t.Fatalf("anonymous: status = %d location = %q, want redirect to /login", w.Code, w.Header().Get("Location"))
+	}
+
+	form := url.Values{"username": {"aa"}, "password": {"secret"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(r, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: status = %d, want 302", w.Code)
+	}
+	if user, _ := s.GetUserByUsername("aa"); !strings.HasPrefix(user.Pwd, "$2a$") {
+		t.Errorf("pw_hash after login = %q, want the plaintext upgraded to bcrypt", user.Pwd)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	addLastCookies(req, w)
+	w = serve(r, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	for _, want := range []string{"My Timeline", "hello from aa", "You were logged in"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("timeline does not contain %q", want)
+		}
+	}
+}
+
+func TestApiMsgsPagination(t *testing.T) {
+	r, s := newTestRouter(t)
+	_ = s.RegisterUser("aa", "a@a.a", "a")
+	for _, text := range []string{"m1", "m2", "m3"} {
+		_ = s.AddMessage(text, 1)
+	}
+	newest, _ := s.GetPublicMessages(store.FirstPage(1))
+	cursor := store.Cursor{PubDate: newest[0].PubDate, MessageID: newest[0].MessageID}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", simulatorAuth)
+		return serve(r, req)
+	}
+
+	w := get("/api/msgs")
+	if link := w.Header().Get("Link"); link != "" {
+		t.Errorf("single page Link = %q, want none", link)
+	}
+
+	w = get("/api/msgs?before=" + cursor.String())
+	var msgs []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &msgs); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusOK || len(msgs) != 2 || msgs[0]["content"] != "m2" || msgs[1]["content"] != "m1" {
+		t.Errorf("older page = %d %v, want [m2 m1]", w.Code, msgs)
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, `rel="prev"`) || strings.Contains(link, `rel="next"`) {
+		t.Errorf("older page Link = %q, want only rel=prev", link)
+	}
+
+	if w := get("/api/msgs?before=garbage"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad cursor: status = %d, want 400", w.Code)
+	}
+}
+
+func TestApiNoParameter(t *testing.T) {
+	r, s := newTestRouter(t)
+	for _, name := range []string{"aa", "bb", "cc"} {
+		_ = s.RegisterUser(name, name+"@x.y", "p")
+		_ = s.AddMessage("from "+name, 1)
+	}
+	_ = s.FollowUser(1, 2)
+	_ = s.FollowUser(1, 3)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", simulatorAuth)
+		return serve(r, req)
+	}
+
+	var msgs []map[string]any
+	w := get("/api/msgs?no=2")
+	if err := json.Unmarshal(w.Body.Bytes(), &msgs); err != nil || len(msgs) != 2 {
+		t.Errorf("/api/msgs?no=2 = %s, want 2 messages", w.Body.String())
+	}
+	var body struct {
+		Follows []string `json:"follows"`
+	}
+	w = get("/api/fllws/aa?no=1")
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Follows) != 1 {
+		t.Errorf("/api/fllws/aa?no=1 = %s, want 1 follow", w.Body.String())
+	}
+	w = get("/api/msgs?no=100000")
+	if w.Code != http.StatusOK {
+		t.Errorf("no above the cap: status = %d, want 200", w.Code)
+	}
+
+	for _, target := range []string{"/api/msgs?no=0", "/api/msgs?no=many", "/api/fllws/aa?no=-1"} {
+		if w := get(target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, w.Code)
+		}
+	}
+}
+
+// failingStore answers the message queries with a database error
+type failingStore struct {
+	*memstore.Store
+}
+
+func (failingStore) GetPublicMessages(store.Page) ([]models.MessageUser, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestApiStoreErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := memstore.New()
+	_ = s.RegisterUser("aa", "a@a.a", "a")
+	h := New(failingStore{s}, password.NewPolicy(password.Bcrypt{Cost: 4}), apiauth.Default())
+	r := gin.New()
+	r.GET("/api/msgs", h.ApiMsgsHandler)
+	r.GET("/api/msgs/:username", h.ApiMsgsPerUserHandler)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", simulatorAuth)
+		return serve(r, req)
+	}
+
+	if w := get("/api/msgs"); w.Code != http.StatusInternalServerError || w.Body.String() != `{"status":500,"error_msg":"Internal server error","code":"internal_error"}` {
+		t.Errorf("database error: %d %s, want 500 and one error body", w.Code, w.Body.String())
+	}
+	if w := get("/api/msgs/nobody"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status = %d, want 404", w.Code)
+	}
+	if w := get("/api/msgs/aa"); w.Code != http.StatusOK {
+		t.Errorf("known user: status = %d, want 200", w.Code)
+	}
+}
+
+func TestModeration(t *testing.T) {
+	r, s := newTestRouter(t)
+	_ = s.RegisterUser("mod", "m@m.m", "secret")
+	_ = s.RegisterUser("aa", "a@a.a", "secret")
+	_ = s.AddMessage("spam", 2)
+
+	request := func(method string, target string, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.SetBasicAuth(auth, "secret")
+		return serve(r, req)
+	}
+
+	for _, tt := range []struct {
+		method, target, auth string
+		want                 int
+	}{
+		{http.MethodPut, "/api/flagged/1", "aa", http.StatusForbidden},
+		{http.MethodPut, "/api/flagged/1", "nobody", http.StatusForbidden},
+		{http.MethodPut, "/api/flagged/x", "mod", http.StatusBadRequest},
+		{http.MethodPut, "/api/flagged/99", "mod", http.StatusNotFound},
+		{http.MethodGet, "/api/flagged", "aa", http.StatusForbidden},
+		{http.MethodPut, "/api/flagged/1", "mod", http.StatusNoContent},
+	} {
+		if w := request(tt.method, tt.target, tt.auth); w.Code != tt.want {
+			t.Errorf("%s %s as %s: status = %d, want %d", tt.method, tt.target, tt.auth, w.Code, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/msgs", nil)
+	req.Header.Set("Authorization", simulatorAuth)
+	if w := serve(r, req); strings.Contains(w.Body.String(), "spam") {
+		t.Errorf("/api/msgs = %s, flagged message is still listed", w.Body.String())
+	}
+
+	var flagged []map[string]any
+	w := request(http.MethodGet, "/api/flagged", "mod")
+	if err := json.Unmarshal(w.Body.Bytes(), &flagged); err != nil || len(flagged) != 1 ||
+		flagged[0]["content"] != "spam" || flagged[0]["flagged_by"] != "mod" || flagged[0]["flagged_at"] == float64(0) {
+		t.Fatalf("/api/flagged = %s, want spam flagged by mod", w.Body.String())
+	}
+
+	form := url.Values{"username": {"mod"}, "password": {"secret"}}
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	login := serve(r, req)
+
+	req = httptest.NewRequest(http.MethodGet, "/flagged", nil)
+	addLastCookies(req, login)
+	if w := serve(r, req); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "flagged by mod") {
+		t.Errorf("/flagged: status = %d, want 200 and the flagged message", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/unflag/1", nil)
+	addLastCookies(req, login)
+	if w := serve(r, req); w.Code != http.StatusFound || w.Header().Get("Location") != "/flagged" {
+		t.Errorf("unflag: status = %d location = %q, want redirect to /flagged", w.Code, w.Header().Get("Location"))
+	}
+	if w := request(http.MethodGet, "/api/flagged", "mod"); w.Body.String() != "[]" {
+		t.Errorf("/api/flagged after unflag = %s, want []", w.Body.String())
+	}
+}
+
+func TestApiDelete(t *testing.T) {
+	r, s := newTestRouter(t)
+	for _, name := range []string{"aa", "bb"} {
+		_ = s.RegisterUser(name, name+"@x.y", "p")
+	}
+	_ = s.AddMessage("by aa", 1)
+	_ = s.AddMessage("by bb", 2)
+	_ = s.FollowUser(1, 2)
+	_ = s.FollowUser(2, 1)
+
+	deleteReq := func(body string, latest int) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/delete?latest="+strconv.Itoa(latest), strings.NewReader(body))
+		req.Header.Set("Authorization", simulatorAuth)
+		return serve(r, req).Code
+	}
+
+	for i, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"user": "aa", "pwd": "p", "message_id": 2}`, http.StatusNotFound}, // written by bb
+		{`{"user": "aa", "pwd": "p", "message_id": "x"}`, http.StatusBadRequest},
+		{`{"user": "aa", "pwd": "p", "message_id": -1}`, http.StatusBadRequest},
+		{`{"user": "nobody", "pwd": "p"}`, http.StatusNotFound},
+		{`{"pwd": "p", "message_id": 1}`, http.StatusBadRequest},
+		{`{"user": "bb", "pwd": "wrong", "message_id": 2}`, http.StatusForbidden},
+		{`{"user": "aa", "message_id": 1}`, http.StatusBadRequest}, // without pwd
+		{`{"user": "bb", "pwd": "p", "message_id": 2}`, http.StatusNoContent},
+		{`{"user": "aa", "pwd": "p"}`, http.StatusNoContent},
+	} {
+		if code := deleteReq(tt.body, i+2); code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.body, code, tt.want)
+		}
+	}
+
+	if latest, _ := s.GetLatest(); latest != 10 {
+		t.Errorf("latest = %d, want 10", latest)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/msgs", nil)
+	req.Header.Set("Authorization", simulatorAuth)
+	if w := serve(r, req); strings.Contains(w.Body.String(), "by ") {
+		t.Errorf("/api/msgs = %s, want no messages", w.Body.String())
+	}
+	if following, _ := s.GetFollowing(2, -1); len(following) != 0 {
+		t.Errorf("bb still follows %v", following)
+	}
+	if _, err := s.GetUserIDByUsername("aa"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("aa: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	r, s := newTestRouter(t)
+	_ = s.RegisterUser("aa", "a@a.a", "secret")
+	_ = s.AddMessage("first", 1)
+	_ = s.AddMessage("second", 1)
+
+	form := url.Values{"username": {"aa"}, "password": {"secret"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	login := serve(r, req)
+
+	post := func(target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		addLastCookies(req, login)
+		return serve(r, req)
+	}
+
+	if w := post("/delete_message/1", nil); w.Code != http.StatusFound {
+		t.Errorf("delete message: status = %d, want 302", w.Code)
+	}
+	if w := post("/delete_message/1", nil); w.Code != http.StatusNotFound {
+		t.Errorf("delete message again: status = %d, want 404", w.Code)
+	}
+	if w := post("/delete_account", url.Values{"password": {"wrong"}}); w.Code != http.StatusFound || w.Header().Get("Location") != "/" {
+		t.Errorf("wrong password: status = %d location = %q, want redirect to /", w.Code, w.Header().Get("Location"))
+	}
+	if _, err := s.GetUserIDByUsername("aa"); err != nil {
+		t.Fatalf("account deleted with a wrong password: %v", err)
+	}
+	if w := post("/delete_account", url.Values{"password": {"secret"}}); w.Code != http.StatusFound || w.Header().Get("Location") != "/public" {
+		t.Errorf("delete account: status = %d location = %q, want redirect to /public", w.Code, w.Header().Get("Location"))
+	}
+	if _, err := s.GetUserIDByUsername("aa"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("aa: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestCommandLog(t *testing.T) {
+	r, s := newTestRouter(t)
+	_ = s.RegisterUser("aa", "a@a.a", "a")
+
+	post := func(target string, body string, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Authorization", auth)
+		return serve(r, req)
+	}
+
+	// a retry of the same id does not post the message a second time
+	for i := 0; i < 2; i++ {
+		if w := post("/api/msgs/aa?latest=5", `{"content": "once"}`, simulatorAuth); w.Code != http.StatusNoContent {
+			t.Errorf("attempt %d: status = %d, want 204", i+1, w.Code)
+		}
+	}
+	if messages, _ := s.GetUserMessages(1, store.FirstPage(10)); len(messages) != 1 {
+		t.Errorf("%d messages stored, want 1", len(messages))
+	}
+
+	// failed commands are replayed too, but do not move latest on
+	for i := 0; i < 2; i++ {
+		if w := post("/api/msgs/nobody?latest=6", `{"content": "lost"}`, simulatorAuth); w.Code != http.StatusNotFound {
+			t.Errorf("unknown user, attempt %d: status = %d, want 404", i+1, w.Code)
+		}
+	}
+	if w := post("/api/msgs/aa?latest=6", `{"content": "lost"}`, simulatorAuth); w.Code != http.StatusConflict {
+		t.Errorf("id reused for another path: status = %d, want 409", w.Code)
+	}
+
+	// requests that are not from the simulator do not take its ids
+	if w := post("/api/msgs/aa?latest=7", `{"content": "spoofed"}`, ""); w.Code != http.StatusForbidden {
+		t.Errorf("without Authorization: status = %d, want 403", w.Code)
+	}
+	if w := post("/api/msgs/aa?latest=7", `{"content": "real"}`, simulatorAuth); w.Code != http.StatusNoContent {
+		t.Errorf("after a spoofed request: status = %d, want 204", w.Code)
+	}
+	if latest, _ := s.GetLatest(); latest != 7 {
+		t.Errorf("latest = %d, want 7", latest)
+	}
+
+	// a request the OpenAPI validation rejects neither records nor moves its id
+	if w := post("/api/msgs/aa?latest=8", `{"content": 5}`, simulatorAuth); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid body: status = %d, want 400", w.Code)
+	}
+	if latest, _ := s.GetLatest(); latest != 7 {
+		t.Errorf("latest after a rejected request = %d, want still 7", latest)
+	}
+	if w := post("/api/msgs/aa?latest=8", `{"content": "fixed"}`, simulatorAuth); w.Code != http.StatusNoContent {
+		t.Errorf("retry with a valid body: status = %d, want 204", w.Code)
+	}
+
+	// reads are not recorded, a retry sees the messages posted since
+	get := func() []any {
+		req := httptest.NewRequest(http.MethodGet, "/api/msgs?latest=9", nil)
+		req.Header.Set("Authorization", simulatorAuth)
+		var msgs []any
+		_ = json.Unmarshal(serve(r, req).Body.Bytes(), &msgs)
+		return msgs
+	}
+	before := len(get())
+	_ = s.AddMessage("since", 1)
+	if after := len(get()); after != before+1 {
+		t.Errorf("retried read = %d messages, want %d", after, before+1)
+	}
+	if latest, _ := s.GetLatest(); latest != 9 {
+		t.Errorf("latest after a read = %d, want 9", latest)
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/metrics"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsHandler records every request in the Prometheus metrics, labelled
+// with the route rather than the path, so /user/:username is one series. The
+// label is in the mux template style (/user/{username}) to line up with the
+// gorilla app.
+func MetricsHandler(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	metrics.ObserveRequest(c.Request.Method, metrics.TemplateRoute(c.FullPath()), c.Writer.Status(), time.Since(start))
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/apierror"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// moderatorKey holds the models.Users of an authenticated moderation request
+const moderatorKey = "moderator"
+
+// moderator authenticates a moderation API request, Basic auth with the
+// moderator's own username and password rather than a simulator client.
+// ModeratorHandler has usually done so already.
+func (h *Handler) moderator(c *gin.Context) (models.Users, bool) {
+	if user, ok := c.Get(moderatorKey); ok {
+		return user.(models.Users), true
+	}
+	user, err := h.Service.AuthenticateModerator(c.GetHeader("Authorization"))
+	if err != nil {
+		abortWithError(c, err)
+		return user, false
+	}
+	c.Set(moderatorKey, user)
+	return user, true
+}
+
+// ModeratorHandler authenticates the moderation API before ValidateHandler runs
+func (h *Handler) ModeratorHandler(c *gin.Context) {
+	h.moderator(c)
+}
+
+// messageID reads the :message_id path parameter, a malformed one aborts with a 400 error body
+func messageID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("message_id"))
+	if err != nil {
+		abortWithError(c, apierror.ErrInvalidMessageID)
+		return 0, false
+	}
+	return id, true
+}
+
+/*
+/api/flagged
+GET
+Lists the flagged messages, the most recently flagged first
+returns: ([{"message_id": 1, ..., "flagged_by": "mod", "flagged_at": 1700000000}], 200)
+*/
+func (h *Handler) ApiFlaggedHandler(c *gin.Context) {
+	user, ok := h.moderator(c)
+	if !ok {
+		return
+	}
+	limit, ok := h.apiLimit(c)
+	if !ok {
+		return
+	}
+	format, ok := dateFormat(c)
+	if !ok {
+		return
+	}
+
+	messages, err := h.Service.FlaggedMessages(user.UserID, limit)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, helpers.FilterFlaggedMessages(messages, format))
+}
+
+/*
+/api/flagged/<message_id>
+PUT flags the message, DELETE unflags it
+returns: ("", 204)
+*/
+func (h *Handler) ApiFlagHandler(c *gin.Context) {
+	user, ok := h.moderator(c)
+	if !ok {
+		return
+	}
+	id, ok := messageID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.SetFlagged(user.UserID, id, c.Request.Method == http.MethodPut); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusNoContent, "")
+}
+
+// FlagMessageHandler serves the moderators' flag (POST /flag/:message_id) and
+// unflag (POST /unflag/:message_id) buttons
+func (h *Handler) FlagMessageHandler(c *gin.Context) {
+	session := sessions.Default(c)
+	userID := session.Get("userID")
+	if userID == nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	id, ok := messageID(c)
+	if !ok {
+		return
+	}
+
+	flagged := strings.HasPrefix(c.FullPath(), "/flag/")
+	if err := h.Service.SetFlagged(userID.(int), id, flagged); err != nil {
+		c.AbortWithStatus(errorStatus(c, err))
+		return
+	}
+
+	// a flagged message is gone from the timelines, an unflagged one from the flagged list
+	next := "/flagged"
+	if flagged {
+		session.AddFlash("The message was flagged")
+		next = "/public"
+	} else {
+		session.AddFlash("The message was unflagged")
+	}
+	if !SaveSessionOrRedirect(c, session.Save(), next) {
+		return
+	}
+	c.Redirect(http.StatusFound, next)
+}
+
+// FlaggedHandler lists the flagged messages to moderators
+func (h *Handler) FlaggedHandler(c *gin.Context) {
+	session := sessions.Default(c)
+	userID := session.Get("userID")
+	if userID == nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	userName, err := h.Store.GetUserNameByUserID(userID.(int))
+	if err != nil {
+		c.AbortWithStatus(errorStatus(c, err))
+		return
+	}
+
+	messages, err := h.Service.FlaggedMessages(userID.(int), h.Limits.Timeline)
+	if err != nil {
+		c.AbortWithStatus(errorStatus(c, err))
+		return
+	}
+
+	flashMessages := session.Flashes()
+	if !SaveSessionOrRedirect(c, session.Save(), "/") {
+		return
+	}
+
+	c.HTML(http.StatusOK, "timeline.html", gin.H{
+		"TimelineBody": true,
+		"Endpoint":     "flagged",
+		"UserID":       userID.(int),
+		"UserName":     userName,
+		"Moderator":    true,
+		"Messages":     helpers.FormatFlaggedMessages(messages),
+		"Flashes":      flashMessages,
+	})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"go-gin/src/internal/auth"
+	"go-minitwit-core/src/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulatorHandler authenticates the simulator's endpoints before
+// ValidateHandler runs, so anonymous callers get the 403 rather than the
+// rules of the API
+func (h *Handler) SimulatorHandler(c *gin.Context) {
+	if err := auth.Simulator(c, h.Clients); err != nil {
+		abortWithError(c, err)
+	}
+}
+
+// ValidateHandler rejects API requests whose parameters or body do not match
+// the OpenAPI document, before they reach the command log and the handlers
+func (h *Handler) ValidateHandler(c *gin.Context) {
+	if err := h.OpenAPI.Validate(c.Request); err != nil {
+		abortWithError(c, err)
+	}
+}
+
+// OpenAPIHandler serves the OpenAPI document of the API
+func (h *Handler) OpenAPIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openapi.Document)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/service"
+	"go-minitwit-core/src/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageFromQuery reads the ?before= / ?after= cursors, a malformed one aborts with a 400 error body
+func pageFromQuery(c *gin.Context, limit int) (store.Page, bool) {
+	page, err := store.ParsePage(c.Query("before"), c.Query("after"), limit)
+	if err != nil {
+		abortWithError(c, err)
+		return page, false
+	}
+	return page, true
+}
+
+// apiLimit reads ?no=, the page size of the API list endpoints
+func (h *Handler) apiLimit(c *gin.Context) (int, bool) {
+	limit, err := store.ParseLimit(c.Query("no"), h.Limits.API, h.Limits.APIMax)
+	if err != nil {
+		abortWithError(c, err)
+		return 0, false
+	}
+	return limit, true
+}
+
+// apiPageFromQuery is pageFromQuery sized by ?no=
+func (h *Handler) apiPageFromQuery(c *gin.Context) (store.Page, bool) {
+	limit, ok := h.apiLimit(c)
+	if !ok {
+		return store.Page{}, false
+	}
+	return pageFromQuery(c, limit)
+}
+
+// dateFormat reads ?date_format=, the pub_date form of the API messages
+func dateFormat(c *gin.Context) (helpers.DateFormat, bool) {
+	format, err := helpers.ParseDateFormat(c.Query("date_format"))
+	if err != nil {
+		abortWithError(c, err)
+		return "", false
+	}
+	return format, true
+}
+
+// setLinkHeader points API clients to the neighbouring pages
+func setLinkHeader(c *gin.Context, timeline service.TimelinePage) {
+	if link := helpers.LinkHeader(c.Request.URL, timeline.Older, timeline.Newer); link != "" {
+		c.Header("Link", link)
+	}
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SaveSessionOrRedirect(c *gin.Context, err error, redirectURL string) bool {
+	if err != nil {
+		fmt.Println("session save failed with:", err)
+		c.Redirect(http.StatusFound, redirectURL)
+		return false
+	}
+	return true
+}
@@ -1,78 +1,88 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"go-gin/src/internal/db"
-	"go-gin/src/internal/helpers"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/service"
+	"go-minitwit-core/src/store"
 	"net/http"
-	"strings"
+	"path"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
 // Handlers
-func UserFollowActionHandler(c *gin.Context) {
+func (h *Handler) UserFollowActionHandler(c *gin.Context) {
 	session := sessions.Default(c)
 
 	userID := session.Get("userID")
 
 	profileUserName := c.Param("username")
-	profileUser, err := db.GetUserByUsername(profileUserName)
-	if err != nil {
-		fmt.Println("get user failed with:", err)
-		c.Redirect(http.StatusFound, "/public")
-		return
-	}
-	profileUserID := profileUser.UserID
 
-	action := c.Param("action")
+	// the route, /:username/follow or /:username/unfollow
+	action := path.Base(c.FullPath())
 
-	if action == "/follow" {
-		err := db.FollowUser(userID.(int), profileUserID)
+	if action == "follow" {
+		err := h.Service.Follow(userID.(int), profileUserName)
+		if errors.Is(err, service.ErrUnknownUser) {
+			c.Redirect(http.StatusFound, "/public")
+			return
+		}
 		if err != nil {
-			fmt.Println("follow user failed with:", err)
-			c.Redirect(http.StatusFound, "/user/"+profileUserName)
+			c.AbortWithStatus(errorStatus(c, err))
 			return
 		}
 		session.AddFlash("You are now following " + profileUserName)
 	}
-	if action == "/unfollow" {
-		err := db.UnfollowUser(userID.(int), profileUserID)
+	if action == "unfollow" {
+		err := h.Service.Unfollow(userID.(int), profileUserName)
+		if errors.Is(err, service.ErrUnknownUser) {
+			c.Redirect(http.StatusFound, "/public")
+			return
+		}
 		if err != nil {
-			fmt.Println("unfollow user failed with:", err)
-			c.Redirect(http.StatusFound, "/user/"+profileUserName)
+			c.AbortWithStatus(errorStatus(c, err))
 			return
 		}
 		session.AddFlash("You are no longer following " + profileUserName)
 	}
 
-	if !helpers.SaveSessionOrRedirect(c, session.Save(), "/user/"+profileUserName) {
+	if !SaveSessionOrRedirect(c, session.Save(), "/user/"+profileUserName) {
 		return
 	}
 	c.Redirect(http.StatusFound, "/user/"+profileUserName)
 }
 
-func PublicTimelineHandler(c *gin.Context) {
-	messages, err := db.GetPublicMessages(30)
+func (h *Handler) PublicTimelineHandler(c *gin.Context) {
+	page, ok := pageFromQuery(c, h.Limits.Timeline)
+	if !ok {
+		return
+	}
+	timeline, err := h.Service.PublicTimeline(page)
 	if err != nil {
+		c.AbortWithStatus(errorStatus(c, err))
 		return
 	}
-	formattedMessages := helpers.FormatMessages(messages)
+	formattedMessages := helpers.FormatMessages(timeline.Messages)
 
 	context := gin.H{
 		"TimelineBody": true,
 		"Endpoint":     "public_timeline",
 		"Messages":     formattedMessages,
+		"Older":        timeline.Older,
+		"Newer":        timeline.Newer,
 	}
 
 	session := sessions.Default(c)
 	userID := session.Get("userID")
 	if userID != nil {
-		userName, errName := db.GetUserNameByUserID(userID.(int))
+		userName, errName := h.Store.GetUserNameByUserID(userID.(int))
 		if errName == nil {
 			context["UserName"] = userName
 			context["UserID"] = userID.(int)
+			context["Moderator"] = h.Service.IsModerator(userID.(int))
 		}
 	}
 
@@ -80,7 +90,7 @@ func PublicTimelineHandler(c *gin.Context) {
 	c.HTML(http.StatusOK, "timeline.html", context)
 }
 
-func UserTimelineHandler(c *gin.Context) {
+func (h *Handler) UserTimelineHandler(c *gin.Context) {
 	session := sessions.Default(c)
 	userID := session.Get("userID")
 	if userID == nil {
@@ -89,41 +99,32 @@ func UserTimelineHandler(c *gin.Context) {
 	}
 	flashMessages := session.Flashes()
 
-	if !helpers.SaveSessionOrRedirect(c, session.Save(), "/") {
+	if !SaveSessionOrRedirect(c, session.Save(), "/") {
 		return
 	}
 
-	profileUserName := c.Param("username")
-	profileUser, err := db.GetUserByUsername(profileUserName)
-
-	if profileUser.Username == "" {
-		fmt.Println("User not found for timeline")
-		c.AbortWithStatus(404)
+	page, ok := pageFromQuery(c, h.Limits.Timeline)
+	if !ok {
 		return
 	}
+	profileUserName := c.Param("username")
+	profileUser, timeline, err := h.Service.UserTimeline(profileUserName, page)
 	if err != nil {
-		if errAbort := c.AbortWithError(http.StatusInternalServerError, err); errAbort != nil {
-			fmt.Printf("Failed to abort with error: %v", errAbort)
-		}
+		c.AbortWithStatus(errorStatus(c, err))
 		return
 	}
 
 	// does the logged in user follow them
-	followed, _ := db.GetFollowing(userID.(int), 30) //TODO: LIMIT OF FOLLOWERS WE QUERY?
-	pUserId := profileUser.UserID
-	profileName := profileUser.Username
-	userName, _ := db.GetUserNameByUserID(userID.(int))
-
-	messages, err := db.GetUserMessages(pUserId, 30)
-
+	followed, err := h.Store.GetFollowing(userID.(int), h.Limits.Following) //TODO: LIMIT OF FOLLOWERS WE QUERY?
 	if err != nil {
-		if errAbort := c.AbortWithError(http.StatusInternalServerError, err); errAbort != nil {
-			fmt.Printf("Failed to abort with error: %v", errAbort)
-		}
+		c.AbortWithStatus(errorStatus(c, err))
 		return
 	}
+	pUserId := profileUser.UserID
+	profileName := profileUser.Username
+	userName, _ := h.Store.GetUserNameByUserID(userID.(int))
 
-	formattedMessages := helpers.FormatMessages(messages)
+	formattedMessages := helpers.FormatMessages(timeline.Messages)
 
 	c.HTML(http.StatusOK, "timeline.html", gin.H{
 		"TimelineBody":    true,
@@ -131,14 +132,17 @@ func UserTimelineHandler(c *gin.Context) {
 		"UserID":          userID.(int),
 		"UserName":        userName,
 		"Messages":        formattedMessages,
+		"Older":           timeline.Older,
+		"Newer":           timeline.Newer,
 		"Followed":        followed,
+		"Moderator":       h.Service.IsModerator(userID.(int)),
 		"ProfileUser":     pUserId,
 		"ProfileUserName": profileName,
 		"Flashes":         flashMessages,
 	})
 }
 
-func MyTimelineHandler(c *gin.Context) {
+func (h *Handler) MyTimelineHandler(c *gin.Context) {
 	session := sessions.Default(c)
 
 	userID := session.Get("userID")
@@ -147,32 +151,32 @@ func MyTimelineHandler(c *gin.Context) {
 		return
 	}
 
-	userName, err := db.GetUserNameByUserID(userID.(int))
+	userName, err := h.Store.GetUserNameByUserID(userID.(int))
+	if errors.Is(err, store.ErrUserNotFound) {
+		c.Redirect(http.StatusSeeOther, "/public")
+		return
+	}
 	if err != nil {
-		if err.Error() == "record not found" {
-			c.Redirect(http.StatusSeeOther, "/public")
-			return
-		}
-		if errAbort := c.AbortWithError(http.StatusInternalServerError, err); errAbort != nil {
-			fmt.Printf("Failed to abort with error: %v", errAbort)
-		}
+		c.AbortWithStatus(errorStatus(c, err))
 		return
 	}
 
 	flashMessages := session.Flashes()
-	if !helpers.SaveSessionOrRedirect(c, session.Save(), "/") {
+	if !SaveSessionOrRedirect(c, session.Save(), "/") {
 		return
 	}
 
-	messages, following, err := db.GetMyMessages(userID.(int))
+	page, ok := pageFromQuery(c, h.Limits.Timeline)
+	if !ok {
+		return
+	}
+	timeline, err := h.Service.MyTimeline(userID.(int), page)
 	if err != nil {
-		if errAbort := c.AbortWithError(http.StatusInternalServerError, err); errAbort != nil {
-			fmt.Printf("Failed to abort with error: %v", errAbort)
-		}
+		c.AbortWithStatus(errorStatus(c, err))
 		return
 	}
 
-	formattedMessages := helpers.FormatMessages(messages)
+	formattedMessages := helpers.FormatMessages(timeline.Messages)
 
 	// For template rendering with Gin
 	c.HTML(http.StatusOK, "timeline.html", gin.H{
@@ -181,13 +185,15 @@ func MyTimelineHandler(c *gin.Context) {
 		"UserID":       userID,
 		"UserName":     userName,
 		"Messages":     formattedMessages,
-		"Followed":     following,
+		"Older":        timeline.Older,
+		"Newer":        timeline.Newer,
+		"Moderator":    h.Service.IsModerator(userID.(int)),
 		"ProfileUser":  userID,
 		"Flashes":      flashMessages,
 	})
 }
 
-func AddMessageHandler(c *gin.Context) {
+func (h *Handler) AddMessageHandler(c *gin.Context) {
 	session := sessions.Default(c)
 	userID := session.Get("userID")
 
@@ -207,22 +213,20 @@ func AddMessageHandler(c *gin.Context) {
 		if text == "" {
 			c.Redirect(http.StatusSeeOther, "/")
 			session.AddFlash("You have to enter a value")
-			if !helpers.SaveSessionOrRedirect(c, session.Save(), "/") {
+			if !SaveSessionOrRedirect(c, session.Save(), "/") {
 				return
 			}
 			return
 		} else {
-			err := db.AddMessage(text, userID.(int))
+			err := h.Store.AddMessage(text, userID.(int))
 			if err != nil {
-				fmt.Println("Error failed to add message")
-				errorData = "Failed to add message"
-				c.Redirect(http.StatusInternalServerError, "/?error="+errorData)
+				c.AbortWithStatus(errorStatus(c, err))
 				return
 			}
 
 			c.Redirect(http.StatusSeeOther, "/")
 			session.AddFlash("Your message was recorded")
-			if !helpers.SaveSessionOrRedirect(c, session.Save(), "/") {
+			if !SaveSessionOrRedirect(c, session.Save(), "/") {
 				return
 			}
 			return
@@ -231,7 +235,63 @@ func AddMessageHandler(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, "/")
 }
 
-func RegisterHandler(c *gin.Context) {
+// DeleteMessageHandler deletes one of the user's own messages (POST /delete_message/:message_id)
+func (h *Handler) DeleteMessageHandler(c *gin.Context) {
+	session := sessions.Default(c)
+	userID := session.Get("userID")
+	if userID == nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	id, ok := messageID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.DeleteMessage(id, userID.(int)); err != nil {
+		c.AbortWithStatus(errorStatus(c, err))
+		return
+	}
+
+	session.AddFlash("Your message was deleted")
+	if !SaveSessionOrRedirect(c, session.Save(), "/") {
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// DeleteAccountHandler deletes the logged in user after asking for their password again
+func (h *Handler) DeleteAccountHandler(c *gin.Context) {
+	session := sessions.Default(c)
+	userID := session.Get("userID")
+	if userID == nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	err := h.Service.DeleteAccount(userID.(int), c.PostForm("password"))
+	if errors.Is(err, service.ErrInvalidPassword) {
+		session.AddFlash("Invalid password, your account was not deleted")
+		if !SaveSessionOrRedirect(c, session.Save(), "/") {
+			return
+		}
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	if err != nil {
+		c.AbortWithStatus(errorStatus(c, err))
+		return
+	}
+
+	session.Clear()
+	session.AddFlash("Your account was deleted")
+	if !SaveSessionOrRedirect(c, session.Save(), "/public") {
+		return
+	}
+	c.Redirect(http.StatusFound, "/public")
+}
+
+func (h *Handler) RegisterHandler(c *gin.Context) {
 	session := sessions.Default(c)
 
 	var errorData string
@@ -253,40 +313,26 @@ func RegisterHandler(c *gin.Context) {
 		password := c.Request.FormValue("password")
 		password2 := c.Request.FormValue("password2")
 
-		userID, err := db.GetUserIDByUsername(userName)
-		if err != nil {
-			if errAbort := c.AbortWithError(http.StatusInternalServerError, err); errAbort != nil {
-				fmt.Printf("Failed to abort with error: %v", errAbort)
-			}
-			return
+		var validationErr *service.ValidationError
+		err = service.ValidateRegistration(userName, email, password, password2)
+		if err == nil {
+			err = h.Service.Register(userName, email, password)
 		}
 
-		if userName == "" {
-			errorData = "You have to enter a username"
-		} else if email == "" || !strings.Contains(email, "@") {
-			errorData = "You have to enter a valid email address"
-		} else if password == "" {
-			errorData = "You have to enter a password"
-		} else if password != password2 {
-			errorData = "The two passwords do not match"
-		} else if fmt.Sprint(userID) != "-1" {
-			errorData = "The username is already taken"
+		if errors.As(err, &validationErr) {
+			errorData = validationErr.Msg
+		} else if err != nil {
+			errorData = "Failed to register user"
+			c.HTML(errorStatus(c, err), "register.html", gin.H{
+				"RegisterBody": true,
+				"Error":        errorData,
+			})
+			return
 		} else {
-			err := db.RegisterUser(userName, email, password)
-			if err != nil {
-				fmt.Println("Failed registration attempt due to an error during registration")
-				errorData = "Failed to register user"
-				c.HTML(http.StatusInternalServerError, "register.html", gin.H{
-					"RegisterBody": true,
-					"Error":        errorData,
-				})
-				return
-			}
-
 			// Redirect to login page after successful registration
 			session.AddFlash("You were successfully registered and can login now")
 
-			if !helpers.SaveSessionOrRedirect(c, session.Save(), "/register") {
+			if !SaveSessionOrRedirect(c, session.Save(), "/register") {
 				return
 			}
 			c.Redirect(http.StatusSeeOther, "/login")
@@ -299,17 +345,17 @@ func RegisterHandler(c *gin.Context) {
 	})
 }
 
-func LoginHandler(c *gin.Context) {
+func (h *Handler) LoginHandler(c *gin.Context) {
 	session := sessions.Default(c)
 	flashMessages := session.Flashes()
-	if !helpers.SaveSessionOrRedirect(c, session.Save(), "/login") {
+	if !SaveSessionOrRedirect(c, session.Save(), "/login") {
 		return
 	}
 
 	userID := session.Get("userID")
 	if userID != nil {
 		session.AddFlash("You were logged in")
-		if !helpers.SaveSessionOrRedirect(c, session.Save(), "/login") {
+		if !SaveSessionOrRedirect(c, session.Save(), "/login") {
 			return
 		}
 		c.Redirect(http.StatusFound, "/")
@@ -333,22 +379,18 @@ func LoginHandler(c *gin.Context) {
 		userName := c.Request.FormValue("username")
 		password := c.Request.FormValue("password")
 
-		user, err := db.GetUserByUsername(userName)
-		if err != nil {
-			if errAbort := c.AbortWithError(http.StatusInternalServerError, err); errAbort != nil {
-				fmt.Printf("Failed to abort with error: %v", errAbort)
-			}
+		user, err := h.Service.Login(userName, password)
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			errorData = validationErr.Msg
+		} else if err != nil {
+			c.AbortWithStatus(errorStatus(c, err))
 			return
-		}
-		if user.Username == "" {
-			errorData = "Invalid username"
-		} else if !helpers.CheckPassword(password, user.Pwd) {
-			errorData = "Invalid password"
 		} else {
 			// Save userID in the session
 			session.Set("userID", user.UserID)
 			session.AddFlash("You were logged in")
-			if !helpers.SaveSessionOrRedirect(c, session.Save(), "/login") {
+			if !SaveSessionOrRedirect(c, session.Save(), "/login") {
 				return
 			}
 
@@ -365,7 +407,7 @@ func LoginHandler(c *gin.Context) {
 	})
 }
 
-func LogoutHandler(c *gin.Context) {
+func (h *Handler) LogoutHandler(c *gin.Context) {
 	session := sessions.Default(c)
 
 	// Clear all session data
@@ -374,7 +416,7 @@ func LogoutHandler(c *gin.Context) {
 	session.AddFlash("You were logged out")
 
 	// Save the session to apply changes
-	if !helpers.SaveSessionOrRedirect(c, session.Save(), "/login") {
+	if !SaveSessionOrRedirect(c, session.Save(), "/login") {
 		return
 	}
 
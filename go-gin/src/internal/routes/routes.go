@@ -2,54 +2,75 @@ package routes
 
 import (
 	"go-gin/src/internal/handlers"
-	"os"
+	"go-minitwit-core/src/config"
+	"go-minitwit-core/src/metrics"
+	"go-minitwit-core/src/sessionstore"
 
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 )
 
-func SetRouteHandlers(r *gin.Engine) {
+func SetRouteHandlers(r *gin.Engine, h *handlers.Handler, sessionStore sessionstore.Store, server config.Server) {
 
-	r.LoadHTMLGlob("templates/*.html")
+	r.LoadHTMLGlob(server.TemplateGlob)
+
+	// Prometheus metrics of every request, the route itself included
+	r.Use(handlers.MetricsHandler)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	if h.Energy != nil {
+		r.Use(h.EnergyHandler)
+		r.GET("/metrics/energy", h.EnergyReportHandler)
+		r.DELETE("/metrics/energy", h.EnergyResetHandler)
+	}
 
 	// sessions, for cookies
-	store := cookie.NewStore([]byte("devops"))
-	r.Use(sessions.Sessions("session", store))
+	r.Use(sessions.Sessions("session", ginSessionStore{sessionStore}))
 
 	// Static (styling)
-	r.Static("static", "static")
+	r.Static("static", server.StaticDir)
 
 	// Define routes -> Here is where the links are being registered! Check the html layout file
 	// user routes
-	r.GET("/", handlers.MyTimelineHandler)
-	r.GET("/public", handlers.PublicTimelineHandler)
-	r.GET("/user/:username", handlers.UserTimelineHandler)
-	r.GET("/register", handlers.RegisterHandler)
-	r.GET("/login", handlers.LoginHandler)
-	r.GET("/logout", handlers.LogoutHandler)
-	r.GET("/:username/*action", handlers.UserFollowActionHandler)
-
-	r.POST("/register", handlers.RegisterHandler)
-	r.POST("/login", handlers.LoginHandler)
-	r.POST("/add_message", handlers.AddMessageHandler)
-
-	// API routes
-	r.GET("/api/msgs", handlers.ApiMsgsHandler)
-	r.GET("/api/msgs/:username", handlers.ApiMsgsPerUserHandler)
-	r.GET("/api/fllws/:username", handlers.ApiFllwsHandler)
-
-	r.POST("/api/register", handlers.ApiRegisterHandler)
-	r.POST("/api/msgs/:username", handlers.ApiMsgsPerUserHandler)
-	r.POST("/api/fllws/:username", handlers.ApiFllwsHandler)
+	r.GET("/", h.MyTimelineHandler)
+	r.GET("/public", h.PublicTimelineHandler)
+	r.GET("/user/:username", h.UserTimelineHandler)
+	r.GET("/register", h.RegisterHandler)
+	r.GET("/login", h.LoginHandler)
+	r.GET("/logout", h.LogoutHandler)
+	r.GET("/:username/follow", h.UserFollowActionHandler)
+	r.GET("/:username/unfollow", h.UserFollowActionHandler)
+
+	r.POST("/register", h.RegisterHandler)
+	r.POST("/login", h.LoginHandler)
+	r.POST("/add_message", h.AddMessageHandler)
+	r.POST("/delete_message/:message_id", h.DeleteMessageHandler)
+	r.POST("/delete_account", h.DeleteAccountHandler)
+	// moderation
+	r.GET("/flagged", h.FlaggedHandler)
+	r.POST("/flag/:message_id", h.FlagMessageHandler)
+	r.POST("/unflag/:message_id", h.FlagMessageHandler)
 
+	// API routes, checked against the OpenAPI document once the caller is
+	// authenticated, so anonymous callers only ever see the 403
+	api := r.Group("/api")
+	api.GET("/openapi.json", h.OpenAPIHandler)
 	// some helper method to "cache" what was the latest simulator action
-	r.GET("/api/latest", handlers.GetLatestHandler)
-}
+	api.GET("/latest", h.ValidateHandler, h.GetLatestHandler)
+
+	// the simulator's commands go through the command log
+	sim := api.Group("", h.SimulatorHandler, h.ValidateHandler, h.CommandLogHandler)
+	sim.GET("/msgs", h.ApiMsgsHandler)
+	sim.GET("/msgs/:username", h.ApiMsgsPerUserHandler)
+	sim.GET("/fllws/:username", h.ApiFllwsHandler)
 
-func LoadEnvVars() string {
-	// Save env-vars
-	dbURL := os.Getenv("DATABASE_URL")
+	sim.POST("/register", h.ApiRegisterHandler)
+	sim.POST("/msgs/:username", h.ApiMsgsPerUserHandler)
+	sim.POST("/fllws/:username", h.ApiFllwsHandler)
+	sim.POST("/delete", h.ApiDeleteHandler)
 
-	return dbURL
+	// moderation API, Basic auth of a moderator
+	mod := api.Group("", h.ModeratorHandler, h.ValidateHandler)
+	mod.GET("/flagged", h.ApiFlaggedHandler)
+	mod.PUT("/flagged/:message_id", h.ApiFlagHandler)
+	mod.DELETE("/flagged/:message_id", h.ApiFlagHandler)
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"go-minitwit-core/src/sessionstore"
+
+	"github.com/gin-contrib/sessions"
+)
+
+// ginSessionStore lets gin-contrib/sessions use any sessionstore backend
+type ginSessionStore struct {
+	sessionstore.Store
+}
+
+func (s ginSessionStore) Options(options sessions.Options) {
+	s.SetOptions(options.ToGorillaOptions())
+}
@@ -1,45 +1,116 @@
 package main
 
 import (
-	"go-gin/src/internal/config"
-	"go-gin/src/internal/db"
+	"context"
+	"go-gin/src/internal/handlers"
 	"go-gin/src/internal/routes"
+	"go-minitwit-core/src/config"
+	"go-minitwit-core/src/db"
+	"go-minitwit-core/src/energy"
+	"go-minitwit-core/src/metrics"
+	"go-minitwit-core/src/migrate"
+	"go-minitwit-core/src/server"
+	"go-minitwit-core/src/sessionstore"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-var err error
-
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	//Set to ReleaseMode to disable logging
-	gin.SetMode(gin.ReleaseMode)
 	/*---------------------
-	 * Load env vars
+	 * Load config
 	 *----------------------*/
-	uri := routes.LoadEnvVars()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// release mode disables the request logging
+	gin.SetMode(cfg.Server.GinMode)
 
 	/*---------------------
 	 * Connect to DB
 	*----------------------*/
-	config.DB, err = db.ConnectDB(uri) // Ensure this matches the function name in the db package
+	gormDB, err := db.ConnectDB(cfg.Database.URL, cfg.Pool())
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if interval := time.Duration(cfg.Database.StatsInterval); interval > 0 {
+		go db.LogStats(ctx, gormDB, interval)
+	}
+
+	/*---------------------
+	 * Migrate, or only that with `migrate up|down|status`
+	 *----------------------*/
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrate.Command(gormDB, os.Args[2:], os.Stdout)
+		_ = db.Close(gormDB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if cfg.Database.Migrate {
+		applied, err := migrate.Up(gormDB)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		for _, m := range applied {
+			log.Println("Applied migration", m)
+		}
+	}
 
 	/*---------------------
 	* Setup routing
 	*----------------------*/
+	passwords, err := cfg.PasswordPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	clients, err := cfg.APIClients()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// "devops" is the key the cookie store used to be hardcoded with
+	sessionStore, err := sessionstore.New(cfg.SessionStore("devops"), gormDB)
+	if err != nil {
+		log.Fatalf("Failed to create session store: %v", err)
+	}
+
+	h := handlers.New(metrics.InstrumentStore(db.NewGormStore(gormDB)), passwords, clients)
+	h.Limits = cfg.Limits
+	h.Service.SetModerators(cfg.Moderation.Moderators)
+	if cfg.Energy.Accounting {
+		sampler, err := energy.NewSampler(cfg.Energy.PowercapDir)
+		if err != nil {
+			log.Printf("Energy accounting disabled: %v", err)
+		} else {
+			if !sampler.RAPL() {
+				log.Println("Energy accounting of CPU time only, no RAPL counters in", cfg.Energy.PowercapDir)
+			}
+			h.Energy = energy.NewMeter(sampler)
+		}
+	}
 	r := gin.New()
-	routes.SetRouteHandlers(r)
+	routes.SetRouteHandlers(r, h, metrics.InstrumentSessions(sessionStore), cfg.Server)
 
 	/*---------------------
-	* Start the server
+	* Serve until SIGTERM/SIGINT, then drain
 	*----------------------*/
-	err = r.Run(":5000") // Start the server on port 5000
+	summary, err := server.New(cfg.Server.Addr, r).Run(ctx, time.Duration(cfg.Server.ShutdownTimeout))
 	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		log.Printf("Server stopped with error: %v", err)
 	}
+	log.Println("Shutdown:", summary)
 
+	if err := db.Close(gormDB); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
 }
@@ -1,24 +1,16 @@
 package auth
 
 import (
-	"encoding/json"
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/apierror"
 	"net/http"
 )
 
-func Is_authenticated(w http.ResponseWriter, r *http.Request) bool {
-	from_simulator := r.Header.Get("Authorization")
-	if from_simulator != "Basic c2ltdWxhdG9yOnN1cGVyX3NhZmUh" {
-		errMsg := "You are not authorized to use this resource!"
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-
-		json.NewEncoder(w).Encode(struct {
-			Status   int    `json:"status"`
-			ErrorMsg string `json:"error_msg"`
-		}{
-			Status:   http.StatusForbidden,
-			ErrorMsg: errMsg,
-		})
+// Is_authenticated authenticates an API request as one of the simulator
+// clients, a rejected request is answered with the 403 error body
+func Is_authenticated(w http.ResponseWriter, r *http.Request, clients *apiauth.Authenticator) bool {
+	if _, err := clients.Authenticate(r.Header.Get("Authorization")); err != nil {
+		apierror.Write(w, err)
 		return false
 	}
 	return true
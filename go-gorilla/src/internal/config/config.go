@@ -3,12 +3,9 @@ package config
 
 import (
 	"text/template"
-
-	"gorm.io/gorm"
 )
 
 var (
 	// Exported global variables
-	DB  *gorm.DB
 	Tpl *template.Template
 )
@@ -4,22 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"go-gorilla/src/internal/auth"
-	"go-gorilla/src/internal/config"
-	"go-gorilla/src/internal/db"
-	"go-gorilla/src/internal/models"
-
+	"go-minitwit-core/src/apierror"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/models"
 	"net/http"
-	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
-func API_Follow(w http.ResponseWriter, r *http.Request) {
-	//Update latest value
-	API_UpdateLatestHandler(w, r)
-
+func (h *Handler) API_Follow(w http.ResponseWriter, r *http.Request) {
 	//Ensure authentication
-	is_auth := auth.Is_authenticated(w, r)
+	is_auth := auth.Is_authenticated(w, r, h.Clients)
 	if !is_auth {
 		fmt.Println("Request denied: not from simulator")
 		return
@@ -30,10 +25,9 @@ func API_Follow(w http.ResponseWriter, r *http.Request) {
 	username := vars["username"]
 
 	//Get userID
-	user_id, err := db.GetUserIDByUsername(username)
-	if err != nil || user_id == -1 {
-		fmt.Println("Error getting user ID", err)
-		w.WriteHeader(http.StatusNotFound)
+	user_id, err := h.Store.GetUserIDByUsername(username)
+	if err != nil {
+		apiError(w, r, err)
 		return
 	}
 
@@ -44,23 +38,16 @@ func API_Follow(w http.ResponseWriter, r *http.Request) {
 
 		// Decode JSON body for POST requests
 		if err := json.NewDecoder(r.Body).Decode(&rv); err != nil {
-			fmt.Println("Error decoding request body:", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			apiError(w, r, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
 			return
 		}
 
 		// Check if it's a follow request
 		if rv.Follow != "" {
-			follow_user_id, err := db.GetUserIDByUsername(rv.Follow)
-			if err != nil || user_id == -1 {
-				fmt.Println("Failed to get user ID for follow/unfollow actions")
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
-
 			// Follow the user
-			if err := db.FollowUser(user_id, follow_user_id); err != nil {
-				w.WriteHeader(http.StatusNotFound)
+			err := h.Service.Follow(user_id, rv.Follow)
+			if err != nil {
+				apiError(w, r, err)
 				return
 			}
 			w.WriteHeader(http.StatusNoContent)
@@ -69,15 +56,9 @@ func API_Follow(w http.ResponseWriter, r *http.Request) {
 
 		// Check if it's an unfollow request
 		if rv.Unfollow != "" {
-			unfollow_user_id, err := db.GetUserIDByUsername(rv.Unfollow)
-			if err != nil || user_id == -1 {
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
-
 			// Unfollow the user
-			if err := db.UnfollowUser(user_id, unfollow_user_id); err != nil {
-				w.WriteHeader(http.StatusNotFound)
+			if err := h.Service.Unfollow(user_id, rv.Unfollow); err != nil {
+				apiError(w, r, err)
 				return
 			}
 
@@ -85,11 +66,16 @@ func API_Follow(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		apiError(w, r, fmt.Errorf("%w: neither follow nor unfollow is set", apierror.ErrMalformedBody))
+
 	} else if r.Method == "GET" {
-		followers, errx := db.GetFollowing(user_id, 100)
-		if errx != nil {
-			fmt.Println("Error getting followers for", username)
-			w.WriteHeader(http.StatusNotFound)
+		limit, ok := h.apiLimit(w, r)
+		if !ok {
+			return
+		}
+		followers, err := h.Store.GetFollowing(user_id, limit)
+		if err != nil {
+			apiError(w, r, err)
 			return
 		}
 
@@ -117,9 +103,10 @@ func API_Follow(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func API_GetLatestHandler(w http.ResponseWriter, r *http.Request) {
-	count, err := db.GetLatest()
+func (h *Handler) API_GetLatestHandler(w http.ResponseWriter, r *http.Request) {
+	count, err := h.Store.GetLatest()
 	if err != nil {
+		apiError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -130,58 +117,35 @@ func API_GetLatestHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func API_UpdateLatestHandler(w http.ResponseWriter, r *http.Request) {
-	// Get the "latest" query parameter
-	parsedCommandID := r.URL.Query().Get("latest")
-
-	// Try to convert the parameter to an integer
-	commandID, err := strconv.Atoi(parsedCommandID)
-
-	if err != nil || parsedCommandID == "" {
-		// Handle the case where the parameter is not present or cannot be converted to an integer
-		commandID = -1
-	}
-
-	if commandID != -1 {
-		// Attempt to update the latest command ID
-		err := db.UpdateLatest(commandID)
-		if err != nil {
-			// Respond with a JSON error message
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update latest value"})
-			return
-		}
-	}
-}
-
-func API_Messages(w http.ResponseWriter, r *http.Request) {
-	//Update latest
-	API_UpdateLatestHandler(w, r)
-
-	is_auth := auth.Is_authenticated(w, r)
+func (h *Handler) API_Messages(w http.ResponseWriter, r *http.Request) {
+	is_auth := auth.Is_authenticated(w, r, h.Clients)
 	if !is_auth {
 		fmt.Println("Unauthorized access attempt to Messages")
 		return
 	}
 
 	if r.Method == "GET" {
-		messages, err := db.GetPublicMessages(100)
-
+		page, ok := h.apiPageFromQuery(w, r)
+		if !ok {
+			return
+		}
+		format, ok := dateFormat(w, r)
+		if !ok {
+			return
+		}
+		timeline, err := h.Service.PublicTimeline(page)
 		if err != nil {
-			fmt.Println("Error encoding JSON response:", err)
-			w.WriteHeader(http.StatusForbidden)
+			apiError(w, r, err)
 			return
 		}
+		setLinkHeader(w, r, timeline)
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(messages)
+		json.NewEncoder(w).Encode(helpers.FilterMessages(timeline.Messages, format))
 	}
 }
 
-func API_Messages_per_user(w http.ResponseWriter, r *http.Request) {
-	//Update latest handler
-	API_UpdateLatestHandler(w, r)
-
-	is_auth := auth.Is_authenticated(w, r)
+func (h *Handler) API_Messages_per_user(w http.ResponseWriter, r *http.Request) {
+	is_auth := auth.Is_authenticated(w, r, h.Clients)
 	if !is_auth {
 		fmt.Println("Unauthorized access attempt to Messages_perUser")
 		return
@@ -190,38 +154,42 @@ func API_Messages_per_user(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
 
-	user_id, err := db.GetUserIDByUsername(username)
-	if err != nil || user_id == -1 {
-		fmt.Println("Error getting user ID", err)
-		w.WriteHeader(http.StatusNotFound)
+	user_id, err := h.Store.GetUserIDByUsername(username)
+	if err != nil {
+		apiError(w, r, err)
 		return
 	}
 
 	if r.Method == "GET" {
-		messages, err := db.GetUserMessages(user_id, 100)
-
+		page, ok := h.apiPageFromQuery(w, r)
+		if !ok {
+			return
+		}
+		format, ok := dateFormat(w, r)
+		if !ok {
+			return
+		}
+		timeline, err := h.Service.UserMessages(user_id, page)
 		if err != nil {
-			fmt.Println("Error encoding JSON response: ", err)
-			w.WriteHeader(http.StatusForbidden)
+			apiError(w, r, err)
 			return
 		}
+		setLinkHeader(w, r, timeline)
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(messages)
+		json.NewEncoder(w).Encode(helpers.FilterMessages(timeline.Messages, format))
 
 	} else if r.Method == "POST" {
-		var rv models.Messages
+		var rv models.MessageData
 
 		err := json.NewDecoder(r.Body).Decode(&rv)
 		if err != nil {
-			fmt.Println("Error decoding request body: ", err)
-			w.WriteHeader(http.StatusForbidden)
+			apiError(w, r, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
 			return
 		}
 
-		err = db.AddMessage(rv.Content, user_id)
+		err = h.Store.AddMessage(rv.Content, user_id)
 		if err != nil {
-			fmt.Println("Error getting user ID", err)
-			w.WriteHeader(http.StatusNotFound)
+			apiError(w, r, err)
 			return
 		}
 
@@ -229,39 +197,57 @@ func API_Messages_per_user(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func API_Register(w http.ResponseWriter, r *http.Request) {
-	//Update latest
-	API_UpdateLatestHandler(w, r)
-
-	is_auth := auth.Is_authenticated(w, r)
+func (h *Handler) API_Register(w http.ResponseWriter, r *http.Request) {
+	is_auth := auth.Is_authenticated(w, r, h.Clients)
 	if !is_auth {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Println("Unauthorized access attempt to Messages_perUser")
+		fmt.Println("Unauthorized access attempt to Register")
 		return
 	}
 
 	var rv models.RegisterData
 	err := json.NewDecoder(r.Body).Decode(&rv)
 	if err != nil {
-		fmt.Println("Error decoding request body: ", err)
-		w.WriteHeader(http.StatusBadRequest)
+		apiError(w, r, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
 		return
 	}
 
-	user, err := db.GetUserByUsername(rv.Username)
-	if err == nil && user.Username != "" {
-		fmt.Println("User already exists: ", err)
-		w.WriteHeader(http.StatusBadRequest)
+	if r.Method == "POST" {
+		if err := h.Service.Register(rv.Username, rv.Email, rv.Pwd); err != nil {
+			apiError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+}
+
+// API_Delete deletes a message of the user ({"user": <username>, "pwd": <password>,
+// "message_id": <message_id>}) or, without a message_id, the user together with their
+// messages and follower relations. The password has to be the user's, as in the UI
+func (h *Handler) API_Delete(w http.ResponseWriter, r *http.Request) {
+	is_auth := auth.Is_authenticated(w, r, h.Clients)
+	if !is_auth {
+		fmt.Println("Unauthorized access attempt to Delete")
 		return
 	}
 
-	if r.Method == "POST" {
-		db.RegisterUser(rv.Username, rv.Email, rv.Pwd)
-		w.WriteHeader(http.StatusNoContent)
+	var rv models.DeleteData
+	if err := json.NewDecoder(r.Body).Decode(&rv); err != nil {
+		apiError(w, r, fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err))
+		return
 	}
-	if config.DB.Error != nil {
-		fmt.Println("Registration error: ", config.DB.Error)
-		w.WriteHeader(http.StatusBadRequest)
+	if rv.User == "" {
+		apiError(w, r, fmt.Errorf("%w: user is not set", apierror.ErrMalformedBody))
+		return
+	}
+	if rv.MessageID < 0 {
+		apiError(w, r, apierror.ErrInvalidMessageID)
+		return
 	}
 
+	if err := h.Service.DeleteOwn(rv.User, rv.Pwd, rv.MessageID); err != nil {
+		apiError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/commandlog"
+	"log"
+	"net/http"
+)
+
+// CommandLog runs the simulator's commands, the API requests with a ?latest=
+// id, through the command log: a retried id is answered with the recorded
+// response and only successful commands move latest on. Reads are not
+// recorded, they run every time. Requests that are not from a simulator client
+// are left to the handlers to reject, so they cannot take an id away from the
+// simulator.
+func (h *Handler) CommandLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := commandlog.ID(r.URL.Query())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := h.Clients.Authenticate(r.Header.Get("Authorization")); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !commandlog.Mutating(r.Method) {
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if err := h.Commands.Read(id, sw.status); err != nil {
+				log.Printf("%s %s: moving latest to %d: %v", r.Method, r.URL.Path, id, err)
+			}
+			return
+		}
+
+		claim, run, err := h.Commands.Begin(id, r.Method, r.URL.Path)
+		if err != nil {
+			apiError(w, r, err)
+			return
+		}
+		if !run {
+			commandlog.Replay(w, claim)
+			return
+		}
+
+		rec := &commandlog.Recorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// the response is out already, a failure only costs the replay
+		if err := h.Commands.Finish(claim, rec.Status, w.Header().Get("Content-Type"), rec.Body.Bytes()); err != nil {
+			log.Printf("%s %s: recording command %d: %v", r.Method, r.URL.Path, id, err)
+		}
+	})
+}
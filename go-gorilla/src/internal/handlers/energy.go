@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-gorilla/src/internal/auth"
+	"go-minitwit-core/src/energy"
+	"net/http"
+	"reflect"
+	"runtime"
+
+	"github.com/gorilla/mux"
+)
+
+// Accounting accounts the CPU time and energy of every request to its route
+// template and the handler that answered it. Like Metrics it only sees
+// matched routes, the router's not found handlers are wrapped as well.
+func (h *Handler) Accounting(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := h.Energy.Begin()
+		next.ServeHTTP(w, r)
+
+		var route, name string
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+			name = handlerName(current.GetHandler())
+		}
+		done(r.Method, route, name)
+	})
+}
+
+// handlerName is the name of the method behind a HandlerFunc, e.g. API_Messages
+func handlerName(handler http.Handler) string {
+	v := reflect.ValueOf(handler)
+	if v.Kind() != reflect.Func {
+		return reflect.TypeOf(handler).String()
+	}
+	return energy.HandlerName(runtime.FuncForPC(v.Pointer()).Name())
+}
+
+// API_Energy serves the accounting per route. Like the reset it is for the
+// simulator clients only, the routes and timings are the app's profile.
+func (h *Handler) API_Energy(w http.ResponseWriter, r *http.Request) {
+	if !auth.Is_authenticated(w, r, h.Clients) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.Energy.Report())
+}
+
+// API_EnergyReset starts the accounting over, e.g. before a measured run.
+// Only the simulator clients may, anyone else could wipe a run.
+func (h *Handler) API_EnergyReset(w http.ResponseWriter, r *http.Request) {
+	if !auth.Is_authenticated(w, r, h.Clients) {
+		return
+	}
+	h.Energy.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
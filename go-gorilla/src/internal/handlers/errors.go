@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/apierror"
+	"log"
+	"net/http"
+)
+
+// logInternal logs err if it is not the client's fault, the response only
+// says that something went wrong
+func logInternal(r *http.Request, err error) {
+	if apierror.Status(err) == http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+// errorStatus is the status to answer err with, the failures that are not the
+// client's fault are logged
+func errorStatus(r *http.Request, err error) int {
+	logInternal(r, err)
+	return apierror.Status(err)
+}
+
+// httpError answers a front-end request with the status of err
+func httpError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(r, err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// apiError answers an API request with the JSON error body of err
+func apiError(w http.ResponseWriter, r *http.Request, err error) {
+	logInternal(r, err)
+	apierror.Write(w, err)
+}
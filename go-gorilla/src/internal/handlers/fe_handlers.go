@@ -1,26 +1,20 @@
 package handlers
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"go-gorilla/src/internal/config"
-	"go-gorilla/src/internal/db"
-	"go-gorilla/src/internal/helpers"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/service"
 	"log"
 
 	"net"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 )
 
-var store = sessions.NewCookieStore([]byte("SESSIONKEY"))
-
-const PER_PAGE = 30
-
 // Data represents the data parsed to the templates.
 type Data struct {
 	Messages      any
@@ -31,11 +25,16 @@ type Data struct {
 	Followed      any
 	FlashMessages []any // Changed to a slice to match the getFlash return type
 	Endpoint      string
+	// Older and Newer are the cursors of the neighbouring timeline pages
+	Older string
+	Newer string
+	// Moderator shows the flag buttons and the link to the flagged messages
+	Moderator bool
 }
 
 // GetUser retrieves the user from the session.
-func GetUser(r *http.Request) (any, int, error) {
-	session, err := GetSession(r)
+func (h *Handler) GetUser(r *http.Request) (any, int, error) {
+	session, err := h.GetSession(r)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -46,7 +45,7 @@ func GetUser(r *http.Request) (any, int, error) {
 	}
 
 	// Query the user from the database
-	user, err := db.GetUserNameByUserID(userID.(int)) // Assuming queryUserByID is defined
+	user, err := h.Store.GetUserNameByUserID(userID.(int)) // Assuming queryUserByID is defined
 	if err != nil {
 		return nil, 0, err
 	}
@@ -55,19 +54,13 @@ func GetUser(r *http.Request) (any, int, error) {
 }
 
 // getSession retrieves the session for the user.
-func GetSession(r *http.Request) (*sessions.Session, error) {
-	return store.Get(r, "user-session")
-}
-
-// """Return the gravatar image for the given email address."""
-func Gravatar_url(email string, size int) string {
-	hash := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(email))))
-	return fmt.Sprintf("http://www.gravatar.com/avatar/%s?d=identicon&s=%d", hex.EncodeToString(hash[:]), size)
+func (h *Handler) GetSession(r *http.Request) (*sessions.Session, error) {
+	return h.Sessions.Get(r, "user-session")
 }
 
 // getFlash retrieves flash messages from the session.
-func GetFlash(w http.ResponseWriter, r *http.Request) []any {
-	session, err := GetSession(r)
+func (h *Handler) GetFlash(w http.ResponseWriter, r *http.Request) []any {
+	session, err := h.GetSession(r)
 	if err != nil {
 		log.Println("Error retrieving session for flash messages:", err)
 		return nil
@@ -78,24 +71,24 @@ func GetFlash(w http.ResponseWriter, r *http.Request) []any {
 	return flashes
 }
 
-func SetFlash(w http.ResponseWriter, r *http.Request, message string) {
-	session, _ := GetSession(r)
+func (h *Handler) SetFlash(w http.ResponseWriter, r *http.Request, message string) {
+	session, _ := h.GetSession(r)
 	session.AddFlash(message)
 	session.Save(r, w)
 }
 
-func Reload(w http.ResponseWriter, r *http.Request, message string, template string) {
+func (h *Handler) Reload(w http.ResponseWriter, r *http.Request, message string, template string) {
 	d := Data{}
 	if message != "" {
-		SetFlash(w, r, message)
+		h.SetFlash(w, r, message)
 	}
-	d.FlashMessages = GetFlash(w, r)
+	d.FlashMessages = h.GetFlash(w, r)
 	config.Tpl.ExecuteTemplate(w, template, d)
 }
 
 // publicTimeline displays the latest messages of all users.
-func Public_timeline(w http.ResponseWriter, r *http.Request) {
-	user, userID, err := GetUser(r)
+func (h *Handler) Public_timeline(w http.ResponseWriter, r *http.Request) {
+	user, userID, err := h.GetUser(r)
 	if err != nil {
 		// Log the error and handle the user not being logged in
 		fmt.Println("public timeline: error retrieving user:", userID, err)
@@ -103,23 +96,29 @@ func Public_timeline(w http.ResponseWriter, r *http.Request) {
 	//TODO: Fix logs above when no user in session
 
 	// Fetch public messages
-	messages, err := db.GetPublicMessages(PER_PAGE)
+	page, ok := pageFromQuery(w, r, h.Limits.Timeline)
+	if !ok {
+		return
+	}
+	timeline, err := h.Service.PublicTimeline(page)
 	if err != nil {
-		fmt.Println("Error fetching public messages:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		httpError(w, r, err)
 		return
 	}
 
 	// Prepare data for rendering
-	flash := GetFlash(w, r)
+	flash := h.GetFlash(w, r)
 	data := Data{
-		Messages:      messages,
+		Messages:      timeline.Messages,
 		UserID:        userID,
 		User:          user,
 		Req:           r.RequestURI,
 		FlashMessages: flash,
 		Followed:      nil,
 		Endpoint:      "public_timeline",
+		Older:         timeline.Older,
+		Newer:         timeline.Newer,
+		Moderator:     h.Service.IsModerator(userID),
 	}
 
 	// Render the template
@@ -131,7 +130,7 @@ func Public_timeline(w http.ResponseWriter, r *http.Request) {
 }
 
 // """Registers the user."""
-func Register(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		config.Tpl.ExecuteTemplate(w, "register.html", nil)
 
@@ -141,63 +140,45 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		password := r.FormValue("password")
 		password2 := r.FormValue("password2")
 
-		userID, err := db.GetUserIDByUsername(username)
-		if err != nil {
-			fmt.Errorf("failed to connect to database: %w", err)
+		var validationErr *service.ValidationError
+		err := service.ValidateRegistration(username, email, password, password2)
+		if err == nil {
+			err = h.Service.Register(username, email, password)
 		}
 
-		if username == "" {
-			Reload(w, r, "You have to enter a username", "register.html")
-			return
-
-		} else if !strings.Contains(email, "@") {
-			Reload(w, r, "You have to enter a valid email address", "register.html")
-			return
-
-		} else if password == "" {
-			Reload(w, r, "You have to enter a password", "register.html")
-			return
-
-		} else if password != password2 {
-			Reload(w, r, "The two passwords do not match", "register.html")
+		if errors.As(err, &validationErr) {
+			h.Reload(w, r, validationErr.Msg, "register.html")
 			return
 
-		} else if fmt.Sprint(userID) != "-1" || err != nil {
-			fmt.Println("error: ", err)
-			Reload(w, r, "The username is already taken", "register.html")
+		} else if err != nil {
+			httpError(w, r, err)
 			return
-
 		} else {
-			err := db.RegisterUser(username, email, password)
-			if err != nil {
-				fmt.Println("error: ", err)
-			}
-			SetFlash(w, r, "You were successfully registered and can login now")
+			h.SetFlash(w, r, "You were successfully registered and can login now")
 			http.Redirect(w, r, "/login", http.StatusFound)
 		}
 	}
 }
 
 // """Logs the user in."""
-func Login(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		Reload(w, r, "", "login.html")
+		h.Reload(w, r, "", "login.html")
 
 	} else if r.Method == "POST" {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
 
-		user, err := db.GetUserByUsername(username)
-		if err != nil || helpers.IsNil(user) {
-			Reload(w, r, "Invalid username", "login.html")
+		user, err := h.Service.Login(username, password)
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			h.Reload(w, r, validationErr.Msg, "login.html")
 			return
-		}
-
-		if !helpers.CheckPassword(password, user.Pwd) {
-			Reload(w, r, "Invalid password", "login.html")
+		} else if err != nil {
+			httpError(w, r, err)
 			return
 		}
-		session, _ := GetSession(r)
+		session, _ := h.GetSession(r)
 		session.Options = &sessions.Options{
 			Path:     "/",
 			MaxAge:   3600, // 1 hour in seconds
@@ -205,19 +186,19 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		}
 		session.Values["user_id"] = user.UserID
 		session.Save(r, w)
-		SetFlash(w, r, "You were logged in")
+		h.SetFlash(w, r, "You were logged in")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 }
 
 // """Logs the user out"""
-func Logout(w http.ResponseWriter, r *http.Request) {
-	session, err := GetSession(r)
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	session, err := h.GetSession(r)
 	if err != nil {
 		fmt.Println("Error getting session data")
 	} else {
-		SetFlash(w, r, "You were logged out")
+		h.SetFlash(w, r, "You were logged out")
 		delete(session.Values, "user_id")
 		err = session.Save(r, w)
 		if err != nil {
@@ -227,29 +208,35 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func MyTimeline(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) MyTimeline(w http.ResponseWriter, r *http.Request) {
 	net.SplitHostPort(r.RemoteAddr)
-	user, user_id, err := GetUser(r)
+	user, user_id, err := h.GetUser(r)
 	if err != nil || helpers.IsNil(user) {
 		http.Redirect(w, r, "/public", http.StatusFound)
 	} else {
 
-		messages, following, err := db.GetMyMessages(user_id)
+		page, ok := pageFromQuery(w, r, h.Limits.Timeline)
+		if !ok {
+			return
+		}
+		timeline, err := h.Service.MyTimeline(user_id, page)
 		if err != nil {
-			fmt.Println("Timeline: Error when trying to query the database", err)
+			httpError(w, r, err)
 			return
 		}
-		flash := GetFlash(w, r)
+		flash := h.GetFlash(w, r)
 		profile_user := user
 
 		d := Data{
 			User:          user,
 			UserID:        user_id,
 			ProfileUser:   profile_user,
-			Messages:      messages,
+			Messages:      timeline.Messages,
 			FlashMessages: flash,
-			Followed:      following,
 			Endpoint:      "my_timeline",
+			Older:         timeline.Older,
+			Newer:         timeline.Newer,
+			Moderator:     h.Service.IsModerator(user_id),
 		}
 
 		err = config.Tpl.ExecuteTemplate(w, "timeline.html", d)
@@ -261,8 +248,8 @@ func MyTimeline(w http.ResponseWriter, r *http.Request) {
 }
 
 // """Registers a new message for the user."""
-func Add_message(w http.ResponseWriter, r *http.Request) {
-	session, err := GetSession(r)
+func (h *Handler) Add_message(w http.ResponseWriter, r *http.Request) {
+	session, err := h.GetSession(r)
 	if err != nil {
 		return
 	}
@@ -274,21 +261,72 @@ func Add_message(w http.ResponseWriter, r *http.Request) {
 	text := r.FormValue("text")
 	if text != "" {
 		// Correct SQL query with pub_date and flagged as integer (0 for unflagged)
-		err := db.AddMessage(text, user_id.(int))
+		err := h.Store.AddMessage(text, user_id.(int))
 
 		if err != nil {
-			http.Error(w, "Unable to add message", http.StatusInternalServerError)
+			httpError(w, r, err)
 			return
 		}
 
-		SetFlash(w, r, "Your message was recorded")
+		h.SetFlash(w, r, "Your message was recorded")
 	}
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
+// """Deletes one of the current user's own messages."""
+func (h *Handler) Delete_message(w http.ResponseWriter, r *http.Request) {
+	user, user_id, err := h.GetUser(r)
+	if err != nil || helpers.IsNil(user) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	id, ok := messageID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Store.DeleteMessage(id, user_id); err != nil {
+		httpError(w, r, err)
+		return
+	}
+	h.SetFlash(w, r, "Your message was deleted")
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// """Deletes the current user after asking for their password again."""
+func (h *Handler) Delete_account(w http.ResponseWriter, r *http.Request) {
+	session, err := h.GetSession(r)
+	if err != nil {
+		return
+	}
+	user_id, ok := session.Values["user_id"].(int)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	err = h.Service.DeleteAccount(user_id, r.FormValue("password"))
+	if errors.Is(err, service.ErrInvalidPassword) {
+		h.SetFlash(w, r, "Invalid password, your account was not deleted")
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+
+	h.SetFlash(w, r, "Your account was deleted")
+	delete(session.Values, "user_id")
+	if err := session.Save(r, w); err != nil {
+		fmt.Println("Error in saving the session data")
+	}
+	http.Redirect(w, r, "/public", http.StatusFound)
+}
+
 // """Adds the current user as follower of the given user."""
-func Follow_user(w http.ResponseWriter, r *http.Request) {
-	session, err := GetSession(r)
+func (h *Handler) Follow_user(w http.ResponseWriter, r *http.Request) {
+	session, err := h.GetSession(r)
 	if err != nil {
 		return
 	}
@@ -300,24 +338,23 @@ func Follow_user(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
 
-	profileUserID, err := db.GetUserIDByUsername(username)
-	if err != nil {
+	err = h.Service.Follow(user_id.(int), username)
+	if errors.Is(err, service.ErrUnknownUser) {
 		http.Error(w, "Followuser: Error when trying to find the user in the database in follow", http.StatusNotFound)
 		return
 	}
-	err = db.FollowUser(user_id.(int), profileUserID)
 	if err != nil {
-		fmt.Println("Error when trying to insert data into the database")
+		httpError(w, r, err)
 		return
 	}
 	message := fmt.Sprintf("You are now following %s", username)
-	SetFlash(w, r, message)
+	h.SetFlash(w, r, message)
 	http.Redirect(w, r, "/user/"+username, http.StatusSeeOther)
 }
 
 // """Removes the current user as follower of the given user."""
-func Unfollow_user(w http.ResponseWriter, r *http.Request) {
-	session, err := GetSession(r)
+func (h *Handler) Unfollow_user(w http.ResponseWriter, r *http.Request) {
+	session, err := h.GetSession(r)
 	if err != nil {
 		return
 	}
@@ -329,24 +366,23 @@ func Unfollow_user(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
 
-	profileUserID, err := db.GetUserIDByUsername(username)
-	if err != nil {
+	err = h.Service.Unfollow(user_id.(int), username)
+	if errors.Is(err, service.ErrUnknownUser) {
 		http.Error(w, "Error when trying to find the user in the database in unfollow", http.StatusNotFound)
 		return
 	}
-	err = db.UnfollowUser(user_id.(int), profileUserID)
 	if err != nil {
-		fmt.Println("Error when trying to delete data from database")
+		httpError(w, r, err)
 		return
 	}
 	message := fmt.Sprintf("You are no longer following %s", username)
-	SetFlash(w, r, message)
+	h.SetFlash(w, r, message)
 	http.Redirect(w, r, "/user/"+username, http.StatusFound)
 }
 
 // """Display's a users tweets."""
-func User_timeline(w http.ResponseWriter, r *http.Request) {
-	user, user_id, err := GetUser(r)
+func (h *Handler) User_timeline(w http.ResponseWriter, r *http.Request) {
+	user, user_id, err := h.GetUser(r)
 	if err != nil || helpers.IsNil(user) {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
@@ -354,33 +390,38 @@ func User_timeline(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
 
-	following, err := db.GetFollowing(user_id, 30) //TODO: LIMIT OF FOLLOWERS WE QUERY?
+	following, err := h.Store.GetFollowing(user_id, h.Limits.Following) //TODO: LIMIT OF FOLLOWERS WE QUERY?
 	if err != nil {
-		fmt.Println("Error when trying to query the database for the following")
+		httpError(w, r, err)
+		return
 	}
-	profile_user, err := db.GetUserByUsername(username)
-	if err != nil || helpers.IsNil(profile_user) {
-		SetFlash(w, r, "The user does not exist")
+	page, ok := pageFromQuery(w, r, h.Limits.Timeline)
+	if !ok {
+		return
+	}
+	profile_user, timeline, err := h.Service.UserTimeline(username, page)
+	if errors.Is(err, service.ErrUnknownUser) {
+		h.SetFlash(w, r, "The user does not exist")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-	profile_user_id := profile_user.UserID
-
-	messages, err := db.GetUserMessages(profile_user_id, 30)
 	if err != nil {
-		fmt.Println("User Timeline: Error when trying to query the database", err)
+		httpError(w, r, err)
 		return
 	}
-	flash := GetFlash(w, r)
+	flash := h.GetFlash(w, r)
 
 	d := Data{
-		Messages:      messages,
+		Messages:      timeline.Messages,
 		User:          user,
 		UserID:        user_id,
 		ProfileUser:   profile_user,
 		FlashMessages: flash,
 		Followed:      following,
 		Endpoint:      "user_timeline",
+		Older:         timeline.Older,
+		Newer:         timeline.Newer,
+		Moderator:     h.Service.IsModerator(user_id),
 	}
 	err = config.Tpl.ExecuteTemplate(w, "timeline.html", d)
 	if err != nil {
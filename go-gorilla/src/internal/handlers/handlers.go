@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/commandlog"
+	"go-minitwit-core/src/config"
+	"go-minitwit-core/src/energy"
+	"go-minitwit-core/src/openapi"
+	"go-minitwit-core/src/password"
+	"go-minitwit-core/src/service"
+	"go-minitwit-core/src/store"
+
+	"github.com/gorilla/sessions"
+)
+
+// Handler carries the dependencies shared by all route handlers
+type Handler struct {
+	Store   store.Store
+	Service *service.Service
+	// Clients are the API credentials accepted on /api
+	Clients *apiauth.Authenticator
+	// Limits are the page sizes, New sets the reference defaults
+	Limits config.Limits
+	// Sessions holds the UI login sessions
+	Sessions sessions.Store
+	// Commands records the simulator's commands, see CommandLog
+	Commands *commandlog.Log
+	// OpenAPI rejects API requests that do not match the document, see Validate
+	OpenAPI *openapi.Validator
+	// Energy accounts CPU time and energy per route, nil turns it off
+	Energy *energy.Meter
+}
+
+func New(s store.Store, passwords *password.Policy, clients *apiauth.Authenticator, sessionStore sessions.Store) *Handler {
+	return &Handler{
+		Store:    s,
+		Service:  service.New(s, passwords),
+		Clients:  clients,
+		Limits:   config.DefaultLimits,
+		Sessions: sessionStore,
+		Commands: commandlog.New(s),
+		OpenAPI:  openapi.Default(),
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/metrics"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// statusWriter remembers the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Metrics records every request in the Prometheus metrics, labelled with the
+// route template rather than the path, so /user/{username} is one series.
+// mux only runs middleware for matched routes, the router's not found and
+// method not allowed handlers are wrapped as well (see routes).
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		var route string
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		metrics.ObserveRequest(r.Method, route, sw.status, time.Since(start))
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-gorilla/src/internal/config"
+	"go-minitwit-core/src/apierror"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// moderatorKey holds the models.Users of an authenticated moderation request
+type moderatorKey struct{}
+
+// moderator authenticates a moderation API request, Basic auth with the
+// moderator's own username and password rather than a simulator client.
+// Moderator has usually done so already.
+func (h *Handler) moderator(w http.ResponseWriter, r *http.Request) (models.Users, bool) {
+	if user, ok := r.Context().Value(moderatorKey{}).(models.Users); ok {
+		return user, true
+	}
+	user, err := h.Service.AuthenticateModerator(r.Header.Get("Authorization"))
+	if err != nil {
+		apiError(w, r, err)
+		return user, false
+	}
+	return user, true
+}
+
+// Moderator authenticates the moderation API before Validate runs
+func (h *Handler) Moderator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := h.moderator(w, r)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), moderatorKey{}, user)))
+	})
+}
+
+// messageID reads the {message_id} path variable, a malformed one is answered with a 400 error body
+func messageID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["message_id"])
+	if err != nil {
+		apiError(w, r, apierror.ErrInvalidMessageID)
+		return 0, false
+	}
+	return id, true
+}
+
+// API_Flagged lists the flagged messages, the most recently flagged first
+func (h *Handler) API_Flagged(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.moderator(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := h.apiLimit(w, r)
+	if !ok {
+		return
+	}
+	format, ok := dateFormat(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := h.Service.FlaggedMessages(user.UserID, limit)
+	if err != nil {
+		apiError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(helpers.FilterFlaggedMessages(messages, format))
+}
+
+// API_Flag flags (PUT) or unflags (DELETE) a message
+func (h *Handler) API_Flag(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.moderator(w, r)
+	if !ok {
+		return
+	}
+	id, ok := messageID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.SetFlagged(user.UserID, id, r.Method == http.MethodPut); err != nil {
+		apiError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// """Flags or unflags a message, the moderators' buttons."""
+func (h *Handler) Flag_message(w http.ResponseWriter, r *http.Request) {
+	user, user_id, err := h.GetUser(r)
+	if err != nil || helpers.IsNil(user) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	id, ok := messageID(w, r)
+	if !ok {
+		return
+	}
+
+	flagged := strings.HasPrefix(r.URL.Path, "/flag/")
+	if err := h.Service.SetFlagged(user_id, id, flagged); err != nil {
+		httpError(w, r, err)
+		return
+	}
+
+	// a flagged message is gone from the timelines, an unflagged one from the flagged list
+	if flagged {
+		h.SetFlash(w, r, "The message was flagged")
+		http.Redirect(w, r, "/public", http.StatusFound)
+		return
+	}
+	h.SetFlash(w, r, "The message was unflagged")
+	http.Redirect(w, r, "/flagged", http.StatusFound)
+}
+
+// """Lists the flagged messages to moderators."""
+func (h *Handler) Flagged(w http.ResponseWriter, r *http.Request) {
+	user, user_id, err := h.GetUser(r)
+	if err != nil || helpers.IsNil(user) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	messages, err := h.Service.FlaggedMessages(user_id, h.Limits.Timeline)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+
+	d := Data{
+		Messages:      messages,
+		User:          user,
+		UserID:        user_id,
+		FlashMessages: h.GetFlash(w, r),
+		Endpoint:      "flagged",
+		Moderator:     true,
+	}
+	if err := config.Tpl.ExecuteTemplate(w, "timeline.html", d); err != nil {
+		fmt.Println("Error when trying to execute the template: ", err)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"go-gorilla/src/internal/auth"
+	"go-minitwit-core/src/openapi"
+	"net/http"
+)
+
+// Simulator authenticates the simulator's endpoints before Validate runs, so
+// anonymous callers get the 403 rather than the rules of the API
+func (h *Handler) Simulator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.Is_authenticated(w, r, h.Clients) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Validate rejects API requests whose parameters or body do not match the
+// OpenAPI document, before they reach the command log and the handlers
+func (h *Handler) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := h.OpenAPI.Validate(r); err != nil {
+			apiError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// API_OpenAPI serves the OpenAPI document of the API
+func (h *Handler) API_OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Document)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/service"
+	"go-minitwit-core/src/store"
+	"net/http"
+)
+
+// pageFromQuery reads the ?before= / ?after= cursors, a malformed one is answered with a 400 error body
+func pageFromQuery(w http.ResponseWriter, r *http.Request, limit int) (store.Page, bool) {
+	q := r.URL.Query()
+	page, err := store.ParsePage(q.Get("before"), q.Get("after"), limit)
+	if err != nil {
+		apiError(w, r, err)
+		return page, false
+	}
+	return page, true
+}
+
+// apiLimit reads ?no=, the page size of the API list endpoints
+func (h *Handler) apiLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	limit, err := store.ParseLimit(r.URL.Query().Get("no"), h.Limits.API, h.Limits.APIMax)
+	if err != nil {
+		apiError(w, r, err)
+		return 0, false
+	}
+	return limit, true
+}
+
+// apiPageFromQuery is pageFromQuery sized by ?no=
+func (h *Handler) apiPageFromQuery(w http.ResponseWriter, r *http.Request) (store.Page, bool) {
+	limit, ok := h.apiLimit(w, r)
+	if !ok {
+		return store.Page{}, false
+	}
+	return pageFromQuery(w, r, limit)
+}
+
+// dateFormat reads ?date_format=, the pub_date form of the API messages
+func dateFormat(w http.ResponseWriter, r *http.Request) (helpers.DateFormat, bool) {
+	format, err := helpers.ParseDateFormat(r.URL.Query().Get("date_format"))
+	if err != nil {
+		apiError(w, r, err)
+		return "", false
+	}
+	return format, true
+}
+
+// setLinkHeader points API clients to the neighbouring pages
+func setLinkHeader(w http.ResponseWriter, r *http.Request, timeline service.TimelinePage) {
+	if link := helpers.LinkHeader(r.URL, timeline.Older, timeline.Newer); link != "" {
+		w.Header().Set("Link", link)
+	}
+}
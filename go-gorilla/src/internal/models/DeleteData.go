@@ -1,6 +0,0 @@
-package models
-
-type DeleteData struct {
-	User    string
-	Message string
-}
@@ -1,6 +0,0 @@
-package models
-
-type Latest struct {
-	ID    int `gorm:"primaryKey"`
-	Value int
-}
@@ -2,12 +2,14 @@ package routes
 
 import (
 	"go-gorilla/src/internal/handlers"
-	"go-gorilla/src/internal/helpers"
-	"go-gorilla/src/internal/models"
+	"go-minitwit-core/src/config"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/metrics"
+	"go-minitwit-core/src/models"
 	"net/http"
-	"os"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -15,9 +17,9 @@ import (
 func SetupRouting() template.FuncMap {
 	funcMap := template.FuncMap{
 		"getavatar": func(url string, size int) string {
-			return handlers.Gravatar_url(url, size)
+			return helpers.GravatarURL(url, size)
 		},
-		"gettimestamp": func(timestamp string) string {
+		"gettimestamp": func(timestamp time.Time) string {
 			return helpers.Format_datetime(timestamp)
 		},
 		"url_for": func(routename, username string) string {
@@ -57,30 +59,61 @@ func SetupRouting() template.FuncMap {
 	return funcMap
 }
 
-func SetRouteHandlers(r *mux.Router) {
+func SetRouteHandlers(r *mux.Router, h *handlers.Handler, server config.Server) {
+	// Prometheus metrics of every request, unmatched ones included
+	notFound := http.NotFoundHandler()
+	var methodNotAllowed http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	r.Use(handlers.Metrics)
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	// CPU time and energy per route, when enabled
+	if h.Energy != nil {
+		r.Use(h.Accounting)
+		notFound, methodNotAllowed = h.Accounting(notFound), h.Accounting(methodNotAllowed)
+		r.HandleFunc("/metrics/energy", h.API_Energy).Methods("GET")
+		r.HandleFunc("/metrics/energy", h.API_EnergyReset).Methods("DELETE")
+	}
+	r.NotFoundHandler = handlers.Metrics(notFound)
+	r.MethodNotAllowedHandler = handlers.Metrics(methodNotAllowed)
+
 	//UI
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-	r.HandleFunc("/public", handlers.Public_timeline)
-	r.HandleFunc("/register", handlers.Register)
-	r.HandleFunc("/login", handlers.Login)
-	r.HandleFunc("/logout", handlers.Logout)
-	r.HandleFunc("/", handlers.MyTimeline)
-	r.HandleFunc("/add_message", handlers.Add_message).Methods("POST")
-	r.HandleFunc("/{username}/follow", handlers.Follow_user)
-	r.HandleFunc("/user/{username}", handlers.User_timeline)
-	r.HandleFunc("/{username}/unfollow", handlers.Unfollow_user)
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(server.StaticDir))))
+	r.HandleFunc("/public", h.Public_timeline)
+	r.HandleFunc("/register", h.Register)
+	r.HandleFunc("/login", h.Login)
+	r.HandleFunc("/logout", h.Logout)
+	r.HandleFunc("/", h.MyTimeline)
+	r.HandleFunc("/add_message", h.Add_message).Methods("POST")
+	r.HandleFunc("/delete_message/{message_id}", h.Delete_message).Methods("POST")
+	r.HandleFunc("/delete_account", h.Delete_account).Methods("POST")
+	r.HandleFunc("/{username}/follow", h.Follow_user)
+	r.HandleFunc("/user/{username}", h.User_timeline)
+	r.HandleFunc("/{username}/unfollow", h.Unfollow_user)
+	r.HandleFunc("/flagged", h.Flagged)
+	r.HandleFunc("/flag/{message_id}", h.Flag_message).Methods("POST")
+	r.HandleFunc("/unflag/{message_id}", h.Flag_message).Methods("POST")
 
-	//API
-	r.HandleFunc("/api/msgs", handlers.API_Messages).Methods("GET").Name("Messages")
-	r.HandleFunc("/api/msgs/{username}", handlers.API_Messages_per_user).Methods("GET", "POST").Name("Messages per user")
-	r.HandleFunc("/api/fllws/{username}", handlers.API_Follow).Methods("GET", "POST").Name("Follow")
-	r.HandleFunc("/api/register", handlers.API_Register).Methods("POST").Name("Follow")
-	r.HandleFunc("/api/latest", handlers.API_GetLatestHandler).Methods("GET").Name("Get latest")
-}
+	//API, checked against the OpenAPI document once the caller is authenticated,
+	//so anonymous callers only ever see the 403
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/openapi.json", h.API_OpenAPI).Methods("GET").Name("OpenAPI")
+	open := api.NewRoute().Subrouter()
+	open.Use(h.Validate)
+	open.HandleFunc("/latest", h.API_GetLatestHandler).Methods("GET").Name("Get latest")
 
-func LoadEnvVars() string {
-	// Save env-vars
-	dbURL := os.Getenv("DATABASE_URL")
+	//the simulator's commands go through the command log
+	sim := api.NewRoute().Subrouter()
+	sim.Use(h.Simulator, h.Validate, h.CommandLog)
+	sim.HandleFunc("/msgs", h.API_Messages).Methods("GET").Name("Messages")
+	sim.HandleFunc("/msgs/{username}", h.API_Messages_per_user).Methods("GET", "POST").Name("Messages per user")
+	sim.HandleFunc("/fllws/{username}", h.API_Follow).Methods("GET", "POST").Name("Follow")
+	sim.HandleFunc("/register", h.API_Register).Methods("POST").Name("Register")
+	sim.HandleFunc("/delete", h.API_Delete).Methods("POST").Name("Delete")
 
-	return dbURL
+	//moderation API, Basic auth of a moderator
+	mod := api.NewRoute().Subrouter()
+	mod.Use(h.Moderator, h.Validate)
+	mod.HandleFunc("/flagged", h.API_Flagged).Methods("GET").Name("Flagged")
+	mod.HandleFunc("/flagged/{message_id}", h.API_Flag).Methods("PUT", "DELETE").Name("Flag")
 }
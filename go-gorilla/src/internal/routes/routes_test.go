@@ -0,0 +1,150 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"go-gorilla/src/internal/config"
+	"go-gorilla/src/internal/handlers"
+	"go-gorilla/src/internal/routes"
+	"go-minitwit-core/src/apiauth"
+	coreconfig "go-minitwit-core/src/config"
+	"go-minitwit-core/src/conformance"
+	"go-minitwit-core/src/energy"
+	"go-minitwit-core/src/memstore"
+	"go-minitwit-core/src/metrics"
+	"go-minitwit-core/src/password"
+	"go-minitwit-core/src/sessionstore"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMain(m *testing.M) {
+	// templates and static files are resolved relative to the app root, like in the Docker image
+	if err := os.Chdir("../../.."); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestConformance(t *testing.T) {
+	tpl, err := template.New("timeline.html").Funcs(routes.SetupRouting()).ParseGlob("templates/*.html")
+	if err != nil {
+		t.Fatalf("parsing templates: %v", err)
+	}
+	config.Tpl = tpl
+
+	for _, backend := range []string{sessionstore.BackendCookie, sessionstore.BackendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			sessionStore, err := sessionstore.New(sessionstore.Config{Backend: backend, Keys: []string{"test"}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := mux.NewRouter()
+			routes.SetRouteHandlers(r, handlers.New(memstore.New(), password.NewPolicy(password.Bcrypt{Cost: 4}), apiauth.Default(), sessionStore), coreconfig.Default().Server)
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			conformance.Run(t, srv.URL)
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	sessionStore, err := sessionstore.New(sessionstore.Config{Backend: sessionstore.BackendCookie, Keys: []string{"test"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	routes.SetRouteHandlers(r, handlers.New(metrics.InstrumentStore(memstore.New()), password.NewPolicy(password.Bcrypt{Cost: 4}), apiauth.Default(), sessionStore), coreconfig.Default().Server)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/latest", nil),
+		httptest.NewRequest("GET", "/api/msgs/aa", nil),
+		httptest.NewRequest("GET", "/a/b/c", nil),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	for _, want := range []string{
+		`minitwit_http_requests_total{method="GET",route="/api/latest",status="200"}`,
+		// the same label in both apps
+		`minitwit_http_requests_total{method="GET",route="/api/msgs/{username}",status="403"}`,
+		`route="unmatched",status="404"}`,
+		`minitwit_db_query_duration_seconds`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("/metrics does not contain %s", want)
+		}
+	}
+}
+
+func TestEnergy(t *testing.T) {
+	sessionStore, err := sessionstore.New(sessionstore.Config{Backend: sessionstore.BackendCookie, Keys: []string{"test"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampler, err := energy.NewSampler(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handlers.New(memstore.New(), password.NewPolicy(password.Bcrypt{Cost: 4}), apiauth.Default(), sessionStore)
+	h.Energy = energy.NewMeter(sampler)
+	r := mux.NewRouter()
+	routes.SetRouteHandlers(r, h, coreconfig.Default().Server)
+
+	for _, target := range []string{"/api/msgs", "/api/msgs", "/api/msgs/aa", "/a/b/c"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	get := httptest.NewRequest("GET", "/metrics/energy", nil)
+	get.SetBasicAuth("simulator", "super_safe!")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, get)
+
+	var report energy.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	requests := map[string]int{}
+	for _, route := range report.Routes {
+		requests[route.Route+" "+route.Handler] = route.Requests
+	}
+	want := map[string]int{
+		"/api/msgs API_Messages":                     2,
+		"/api/msgs/{username} API_Messages_per_user": 1,
+		"unmatched ": 1,
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests per route = %v, want %v", requests, want)
+	}
+
+	// only the simulator may read or reset a run
+	for _, method := range []string{"GET", "DELETE"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/metrics/energy", nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("anonymous %s: status = %d, want 403", method, w.Code)
+		}
+	}
+	reset := httptest.NewRequest("DELETE", "/metrics/energy", nil)
+	reset.SetBasicAuth("simulator", "super_safe!")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, reset)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("reset: status = %d, want 204", w.Code)
+	}
+	if report := h.Energy.Report(); report.Total.Requests != 1 {
+		t.Errorf("requests after reset = %d, want only the DELETE itself", report.Total.Requests)
+	}
+}
@@ -1,40 +1,78 @@
 package main
 
 import (
+	"context"
 	"go-gorilla/src/internal/config"
-	"go-gorilla/src/internal/db"
+	"go-gorilla/src/internal/handlers"
 	"go-gorilla/src/internal/routes"
+	coreconfig "go-minitwit-core/src/config"
+	"go-minitwit-core/src/db"
+	"go-minitwit-core/src/energy"
+	"go-minitwit-core/src/metrics"
+	"go-minitwit-core/src/migrate"
+	"go-minitwit-core/src/server"
+	"go-minitwit-core/src/sessionstore"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 )
 
-var err error
-
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	/*----------------------
-	 * Load env vars
+	 * Load config
 	 *----------------------*/
-	uri := routes.LoadEnvVars()
+	cfg, err := coreconfig.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	/*-----------------------
 	 * Connect to DB
 	 *----------------------*/
-	config.DB, err = db.ConnectDB(uri) // Ensure this matches the function name in the db package
+	gormDB, err := db.ConnectDB(cfg.Database.URL, cfg.Pool())
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if interval := time.Duration(cfg.Database.StatsInterval); interval > 0 {
+		go db.LogStats(ctx, gormDB, interval)
+	}
+
+	/*---------------------
+	 * Migrate, or only that with `migrate up|down|status`
+	 *----------------------*/
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrate.Command(gormDB, os.Args[2:], os.Stdout)
+		_ = db.Close(gormDB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if cfg.Database.Migrate {
+		applied, err := migrate.Up(gormDB)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		for _, m := range applied {
+			log.Println("Applied migration", m)
+		}
+	}
 
 	/*---------------------
 	* Setup routing
 	*----------------------*/
 	funcMap := routes.SetupRouting()
 
-	config.Tpl, err = template.New("timeline.html").Funcs(funcMap).ParseGlob("templates/*.html")
+	config.Tpl, err = template.New("timeline.html").Funcs(funcMap).ParseGlob(cfg.Server.TemplateGlob)
 	if err != nil {
 		log.Fatalf("Error parsing template: %v", err)
 	}
@@ -42,10 +80,47 @@ func main() {
 	/*---------------------
 	* Setup route-handlers
 	*----------------------*/
+	passwords, err := cfg.PasswordPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	clients, err := cfg.APIClients()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// "SESSIONKEY" is the key the cookie store used to be hardcoded with
+	sessionStore, err := sessionstore.New(cfg.SessionStore("SESSIONKEY"), gormDB)
+	if err != nil {
+		log.Fatalf("Failed to create session store: %v", err)
+	}
+
+	h := handlers.New(metrics.InstrumentStore(db.NewGormStore(gormDB)), passwords, clients, metrics.InstrumentSessions(sessionStore))
+	h.Limits = cfg.Limits
+	h.Service.SetModerators(cfg.Moderation.Moderators)
+	if cfg.Energy.Accounting {
+		sampler, err := energy.NewSampler(cfg.Energy.PowercapDir)
+		if err != nil {
+			log.Printf("Energy accounting disabled: %v", err)
+		} else {
+			if !sampler.RAPL() {
+				log.Println("Energy accounting of CPU time only, no RAPL counters in", cfg.Energy.PowercapDir)
+			}
+			h.Energy = energy.NewMeter(sampler)
+		}
+	}
 	r := mux.NewRouter()
-	routes.SetRouteHandlers(r)
-	err = http.ListenAndServe(":5000", r)
+	routes.SetRouteHandlers(r, h, cfg.Server)
+
+	/*---------------------
+	* Serve until SIGTERM/SIGINT, then drain
+	*----------------------*/
+	summary, err := server.New(cfg.Server.Addr, r).Run(ctx, time.Duration(cfg.Server.ShutdownTimeout))
 	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		log.Printf("Server stopped with error: %v", err)
+	}
+	log.Println("Shutdown:", summary)
+
+	if err := db.Close(gormDB); err != nil {
+		log.Printf("Failed to close database: %v", err)
 	}
 }
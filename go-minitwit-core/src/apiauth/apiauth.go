@@ -0,0 +1,108 @@
+// Package apiauth authenticates the API clients (the simulator) by HTTP Basic auth.
+//
+// Credentials are a comma separated list of user:password pairs
+// (SIMULATOR_CREDENTIALS, see package config), e.g. "simulator:super_safe!,sim-b:other". Every pair is a
+// separate client, so several simulator instances can be told apart.
+package apiauth
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultCredentials are the ones hardcoded in the reference simulator
+const DefaultCredentials = "simulator:super_safe!"
+
+var (
+	ErrMissing      = errors.New("missing basic auth credentials")
+	ErrMalformed    = errors.New("malformed basic auth header")
+	ErrUnauthorized = errors.New("unknown client or wrong password")
+)
+
+// Principal is an authenticated API client
+type Principal struct {
+	Client string
+}
+
+// Authenticator checks Authorization headers against the configured clients
+type Authenticator struct {
+	clients map[string]string
+}
+
+// Parse builds an Authenticator from a "user:password,user2:password2" list
+func Parse(spec string) (*Authenticator, error) {
+	clients := map[string]string{}
+	for i, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		user, pass, ok := strings.Cut(pair, ":")
+		if !ok || user == "" || pass == "" {
+			// only the position, the malformed entry may well be a password
+			return nil, fmt.Errorf("api credentials: entry %d: want user:password", i+1)
+		}
+		if _, dup := clients[user]; dup {
+			return nil, fmt.Errorf("api credentials: client %q given twice", user)
+		}
+		clients[user] = pass
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("api credentials: no clients configured")
+	}
+	return &Authenticator{clients: clients}, nil
+}
+
+// Default accepts only the reference simulator
+func Default() *Authenticator {
+	a, _ := Parse(DefaultCredentials)
+	return a
+}
+
+// Clients lists the configured client names
+func (a *Authenticator) Clients() []string {
+	names := make([]string, 0, len(a.clients))
+	for name := range a.clients {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Authenticate checks the value of an Authorization header
+func (a *Authenticator) Authenticate(header string) (Principal, error) {
+	user, pass, err := ParseBasic(header)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	want, known := a.clients[user]
+	// compare anyway so unknown clients take as long as wrong passwords
+	match := subtle.ConstantTimeCompare([]byte(pass), []byte(want)) == 1
+	if !known || !match {
+		return Principal{}, ErrUnauthorized
+	}
+	return Principal{Client: user}, nil
+}
+
+// ParseBasic splits a "Basic base64(user:password)" header (RFC 7617)
+func ParseBasic(header string) (user string, pass string, err error) {
+	if header == "" {
+		return "", "", ErrMissing
+	}
+	scheme, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return "", "", ErrMalformed
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", ErrMalformed
+	}
+	user, pass, ok = strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", ErrMalformed
+	}
+	return user, pass, nil
+}
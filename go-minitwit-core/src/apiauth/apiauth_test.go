@@ -0,0 +1,61 @@
+package apiauth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func basic(userPass string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(userPass))
+}
+
+func TestDefaultMatchesSimulatorHeader(t *testing.T) {
+	p, err := Default().Authenticate("Basic c2ltdWxhdG9yOnN1cGVyX3NhZmUh")
+	if err != nil || p.Client != "simulator" {
+		t.Fatalf("Authenticate = %v, %v, want simulator", p, err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a, err := Parse("sim-a:one, sim-b:two:with:colons")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		header string
+		client string
+		err    error
+	}{
+		{basic("sim-a:one"), "sim-a", nil},
+		{basic("sim-b:two:with:colons"), "sim-b", nil},
+		{"basic " + base64.StdEncoding.EncodeToString([]byte("sim-a:one")), "sim-a", nil},
+		{basic("sim-a:two:with:colons"), "", ErrUnauthorized},
+		{basic("nobody:one"), "", ErrUnauthorized},
+		{"", "", ErrMissing},
+		{"Bearer abc", "", ErrMalformed},
+		{"Basic not-base64!", "", ErrMalformed},
+		{basic("no-colon"), "", ErrMalformed},
+	}
+	for _, tt := range tests {
+		p, err := a.Authenticate(tt.header)
+		if !errors.Is(err, tt.err) || p.Client != tt.client {
+			t.Errorf("Authenticate(%q) = %q, %v, want %q, %v", tt.header, p.Client, err, tt.client, tt.err)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, spec := range []string{"", " , ", "nopassword", ":pw", "a:1,a:2"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) accepted", spec)
+		}
+	}
+
+	_, err := Parse("sim:ok,hunter2")
+	if err == nil || strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), "entry 2") {
+		t.Errorf("Parse of a password without user: err = %v, want entry 2 and no password", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Package apierror maps the errors of the core packages to HTTP statuses and
+// JSON error bodies, so both front-ends answer the same failure with the same
+// status and the same bytes.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/commandlog"
+	"go-minitwit-core/src/helpers"
+	"go-minitwit-core/src/service"
+	"go-minitwit-core/src/store"
+	"net/http"
+)
+
+// Errors of requests the handlers reject before reaching the core
+var (
+	ErrMalformedBody    = errors.New("malformed request body")
+	ErrBodyTooLarge     = errors.New("request body too large")
+	ErrInvalidMessageID = errors.New("invalid message id")
+	// ErrInvalidRequest is a request that does not match the OpenAPI document
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Codes of the error bodies, stable for clients to switch on
+const (
+	CodeInvalidInput      = "invalid_input"
+	CodeWrongPassword     = "wrong_password"
+	CodeUsernameTaken     = "username_taken"
+	CodeUserNotFound      = "user_not_found"
+	CodeMessageNotFound   = "message_not_found"
+	CodeInvalidCursor     = "invalid_cursor"
+	CodeInvalidLimit      = "invalid_limit"
+	CodeInvalidDateFormat = "invalid_date_format"
+	CodeMalformedBody     = "malformed_body"
+	CodeBodyTooLarge      = "body_too_large"
+	CodeInvalidMessageID  = "invalid_message_id"
+	CodeInvalidRequest    = "invalid_request"
+	CodeUnauthorized      = "unauthorized"
+	CodeNotModerator      = "not_moderator"
+	CodeCommandInProgress = "command_in_progress"
+	CodeCommandReused     = "command_id_reused"
+	CodeInternal          = "internal_error"
+)
+
+// Error is the JSON body of every failed API request
+type Error struct {
+	Status   int    `json:"status"`
+	ErrorMsg string `json:"error_msg"`
+	Code     string `json:"code"`
+}
+
+// kind describes the response to the errors matching target. msg is fixed
+// per kind, the error's own text names rejected values and is not for clients
+type kind struct {
+	target error
+	status int
+	code   string
+	msg    string
+}
+
+// unauthorizedMsg is the reference Minitwit's message for simulator requests
+// without valid credentials
+const unauthorizedMsg = "You are not authorized to use this resource!"
+
+// kinds is checked in order, the first match wins. The taken username and
+// the wrong password come before the generic validation errors they are one of
+var kinds = []kind{
+	{service.ErrUsernameTaken, http.StatusBadRequest, CodeUsernameTaken, service.ErrUsernameTaken.Msg},
+	{service.ErrInvalidPassword, http.StatusForbidden, CodeWrongPassword, service.ErrInvalidPassword.Msg},
+	{store.ErrDuplicateUsername, http.StatusBadRequest, CodeUsernameTaken, service.ErrUsernameTaken.Msg},
+	{store.ErrUserNotFound, http.StatusNotFound, CodeUserNotFound, "User not found"},
+	{store.ErrMessageNotFound, http.StatusNotFound, CodeMessageNotFound, "Message not found"},
+	{store.ErrInvalidCursor, http.StatusBadRequest, CodeInvalidCursor, "Invalid cursor"},
+	{store.ErrInvalidLimit, http.StatusBadRequest, CodeInvalidLimit, "Invalid limit"},
+	{helpers.ErrInvalidDateFormat, http.StatusBadRequest, CodeInvalidDateFormat, "Invalid date format"},
+	{ErrMalformedBody, http.StatusBadRequest, CodeMalformedBody, "Malformed request body"},
+	{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "Request body too large"},
+	{ErrInvalidMessageID, http.StatusBadRequest, CodeInvalidMessageID, "Invalid message id"},
+	{ErrInvalidRequest, http.StatusBadRequest, CodeInvalidRequest, "Invalid request"},
+	{apiauth.ErrMissing, http.StatusForbidden, CodeUnauthorized, unauthorizedMsg},
+	{apiauth.ErrMalformed, http.StatusForbidden, CodeUnauthorized, unauthorizedMsg},
+	{apiauth.ErrUnauthorized, http.StatusForbidden, CodeUnauthorized, unauthorizedMsg},
+	{service.ErrNotModerator, http.StatusForbidden, CodeNotModerator, "Moderator rights required"},
+	{commandlog.ErrInProgress, http.StatusConflict, CodeCommandInProgress, "Command is still running"},
+	{commandlog.ErrReused, http.StatusConflict, CodeCommandReused, "Command id was used for a different request"},
+}
+
+// Status is the HTTP status for err, 500 for anything that is not the
+// client's fault
+func Status(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return For(err).Status
+}
+
+// For is the error body for err. Anything that is not the client's fault is
+// an internal error whose details are for the log only
+func For(err error) Error {
+	var validationErr *service.ValidationError
+	for _, k := range kinds {
+		if errors.Is(err, k.target) {
+			return Error{Status: k.status, ErrorMsg: k.msg, Code: k.code}
+		}
+	}
+	if errors.As(err, &validationErr) {
+		return Error{Status: http.StatusBadRequest, ErrorMsg: validationErr.Msg, Code: CodeInvalidInput}
+	}
+	return Error{Status: http.StatusInternalServerError, ErrorMsg: "Internal server error", Code: CodeInternal}
+}
+
+// Write answers the request with the error body for err. It marshals the
+// body itself rather than leaving it to a framework, so the bytes do not
+// depend on the front-end
+func Write(w http.ResponseWriter, err error) {
+	e := For(err)
+	body, _ := json.Marshal(e)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(e.Status)
+	w.Write(body)
+}
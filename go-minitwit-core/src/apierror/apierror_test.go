@@ -0,0 +1,78 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/commandlog"
+	"go-minitwit-core/src/service"
+	"go-minitwit-core/src/store"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{fmt.Errorf("getting user nobody: %w", store.ErrUserNotFound), http.StatusNotFound},
+		{service.ErrUnknownUser, http.StatusNotFound},
+		{service.ErrUsernameTaken, http.StatusBadRequest},
+		{&service.ValidationError{Msg: "You have to enter a password"}, http.StatusBadRequest},
+		{fmt.Errorf("registering aa: %w", store.ErrDuplicateUsername), http.StatusBadRequest},
+		{store.ErrInvalidLimit, http.StatusBadRequest},
+		{fmt.Errorf("setting flagged of message 7: %w", store.ErrMessageNotFound), http.StatusNotFound},
+		{service.ErrNotModerator, http.StatusForbidden},
+		{service.ErrInvalidPassword, http.StatusForbidden},
+		{commandlog.ErrInProgress, http.StatusConflict},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := Status(tt.err); got != tt.want {
+			t.Errorf("Status(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Error
+	}{
+		{service.ErrUsernameTaken, Error{400, "The username is already taken", CodeUsernameTaken}},
+		{fmt.Errorf("registering aa: %w", store.ErrDuplicateUsername), Error{400, "The username is already taken", CodeUsernameTaken}},
+		{&service.ValidationError{Msg: "You have to enter a password"}, Error{400, "You have to enter a password", CodeInvalidInput}},
+		{service.ErrInvalidPassword, Error{403, "Invalid password", CodeWrongPassword}},
+		{fmt.Errorf("getting user nobody: %w", store.ErrUserNotFound), Error{404, "User not found", CodeUserNotFound}},
+		{fmt.Errorf("%w %q, want a positive number", store.ErrInvalidLimit, "x"), Error{400, "Invalid limit", CodeInvalidLimit}},
+		{fmt.Errorf("%w: unexpected EOF", ErrMalformedBody), Error{400, "Malformed request body", CodeMalformedBody}},
+		{fmt.Errorf("%w: more than 16384 bytes", ErrBodyTooLarge), Error{413, "Request body too large", CodeBodyTooLarge}},
+		{apiauth.ErrMissing, Error{403, "You are not authorized to use this resource!", CodeUnauthorized}},
+		{fmt.Errorf("%w: query parameter \"no\" must be an integer", ErrInvalidRequest), Error{400, "Invalid request", CodeInvalidRequest}},
+		{commandlog.ErrReused, Error{409, "Command id was used for a different request", CodeCommandReused}},
+		{errors.New("connection refused"), Error{500, "Internal server error", CodeInternal}},
+	}
+	for _, tt := range tests {
+		if got := For(tt.err); got != tt.want {
+			t.Errorf("For(%v) = %+v, want %+v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, store.ErrUserNotFound)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if want := `{"status":404,"error_msg":"User not found","code":"user_not_found"}`; w.Body.String() != want {
+		t.Errorf("body = %s, want %s", w.Body, want)
+	}
+}
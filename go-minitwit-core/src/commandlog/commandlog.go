@@ -0,0 +1,139 @@
+// Package commandlog makes the simulator's API commands idempotent. The
+// simulator numbers its requests with ?latest=; the first request with a
+// number runs and its response is recorded, a retry of the number is answered
+// with the recorded response instead of running the command again. Only
+// successful commands move /api/latest on, and a command that failed on the
+// server side (5xx) is forgotten so that its retry runs again.
+//
+// Only commands that change something (POST, PUT, DELETE, ...) are recorded.
+// Reads such as GET /api/msgs run on every retry and are answered with the
+// current state, they only move latest on. Requests rejected before the log,
+// e.g. by the OpenAPI validation, neither take up nor move their id.
+package commandlog
+
+import (
+	"bytes"
+	"errors"
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/store"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ClaimTimeout is how long a command may run before a retry of its id takes
+// it over, e.g. after the app was stopped in the middle of the request
+const ClaimTimeout = time.Minute
+
+var (
+	// ErrInProgress is returned by Begin while another request runs the command
+	ErrInProgress = errors.New("command is still running")
+	// ErrReused is returned by Begin for an id that was used by another endpoint
+	ErrReused = errors.New("command id was used for a different request")
+)
+
+type Log struct {
+	store store.Store
+
+	// Now stamps the commands, tests may replace it
+	Now func() time.Time
+}
+
+func New(s store.Store) *Log {
+	return &Log{store: s, Now: time.Now}
+}
+
+// ID returns the command id of a request, false when ?latest= is missing or
+// not a non-negative number; such requests bypass the log
+func ID(query url.Values) (int, bool) {
+	id, err := strconv.Atoi(query.Get("latest"))
+	return id, err == nil && id >= 0
+}
+
+// Mutating reports whether requests with method change state and are
+// recorded, the others go through Read
+func Mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// Read moves latest on to id after a read-only command answered with status.
+// Nothing is recorded, a retry runs again.
+func (l *Log) Read(id int, status int) error {
+	if status == 0 {
+		status = http.StatusOK // the handler wrote nothing
+	}
+	if status >= http.StatusMultipleChoices {
+		return nil
+	}
+	return l.store.UpdateLatest(id)
+}
+
+// Begin claims command id for a request. It returns the claim and true when
+// the request has to run, or the recorded command and false for a retry that
+// is answered with Replay.
+func (l *Log) Begin(id int, method string, path string) (models.Command, bool, error) {
+	claim := models.Command{CommandID: id, Method: method, Path: path, CreatedAt: l.Now().UTC()}
+	stored, claimed, err := l.store.ClaimCommand(claim, claim.CreatedAt.Add(-ClaimTimeout))
+	switch {
+	case err != nil:
+		return stored, false, err
+	case claimed:
+		return stored, true, nil
+	case stored.Method != method || stored.Path != path:
+		return stored, false, ErrReused
+	case stored.Status == 0:
+		return stored, false, ErrInProgress
+	}
+	return stored, false, nil
+}
+
+// Finish records the response to a claimed command
+func (l *Log) Finish(claim models.Command, status int, contentType string, body []byte) error {
+	if status == 0 {
+		status = http.StatusOK // the handler wrote nothing
+	}
+	if status >= http.StatusInternalServerError {
+		return l.store.ReleaseCommand(claim.CommandID)
+	}
+	claim.Status = status
+	claim.ContentType = contentType
+	claim.Body = string(body)
+	claim.CreatedAt = l.Now().UTC()
+	return l.store.CompleteCommand(claim, status < http.StatusMultipleChoices)
+}
+
+// Replay writes the recorded response of cmd
+func Replay(w http.ResponseWriter, cmd models.Command) {
+	if cmd.ContentType != "" {
+		w.Header().Set("Content-Type", cmd.ContentType)
+	}
+	w.WriteHeader(cmd.Status)
+	w.Write([]byte(cmd.Body))
+}
+
+// Recorder is an http.ResponseWriter that keeps a copy of the response for Finish
+type Recorder struct {
+	http.ResponseWriter
+	Status int
+	Body   bytes.Buffer
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if r.Status == 0 {
+		r.Status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	r.Body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,97 @@
+package commandlog
+
+import (
+	"errors"
+	"go-minitwit-core/src/memstore"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestID(t *testing.T) {
+	for query, want := range map[string]int{"latest=7": 7, "latest=0": 0, "latest=-1": -1, "latest=x": -1, "": -1} {
+		values, _ := url.ParseQuery(query)
+		id, ok := ID(values)
+		if (want >= 0) != ok || (ok && id != want) {
+			t.Errorf("ID(%q) = %d, %v, want %d", query, id, ok, want)
+		}
+	}
+}
+
+func TestBeginFinish(t *testing.T) {
+	s := memstore.New()
+	l := New(s)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.Now = func() time.Time { return now }
+
+	claim, run, err := l.Begin(1, http.MethodPost, "/api/register")
+	if err != nil || !run {
+		t.Fatalf("first Begin = %v, %v, want to run", run, err)
+	}
+	if _, _, err := l.Begin(1, http.MethodPost, "/api/register"); !errors.Is(err, ErrInProgress) {
+		t.Errorf("Begin while running: err = %v, want ErrInProgress", err)
+	}
+	if err := l.Finish(claim, http.StatusNoContent, "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := s.GetLatest(); latest != 1 {
+		t.Errorf("latest = %d, want 1", latest)
+	}
+
+	stored, run, err := l.Begin(1, http.MethodPost, "/api/register")
+	if err != nil || run || stored.Status != http.StatusNoContent {
+		t.Errorf("retry = %+v, %v, %v, want the recorded 204", stored, run, err)
+	}
+	if _, _, err := l.Begin(1, http.MethodPost, "/api/msgs/aa"); !errors.Is(err, ErrReused) {
+		t.Errorf("Begin for another path: err = %v, want ErrReused", err)
+	}
+
+	// client errors are recorded but do not move latest, server errors are forgotten
+	claim, _, _ = l.Begin(2, http.MethodPost, "/api/msgs/aa")
+	_ = l.Finish(claim, http.StatusNotFound, "", nil)
+	claim, _, _ = l.Begin(3, http.MethodPost, "/api/msgs/aa")
+	_ = l.Finish(claim, http.StatusInternalServerError, "", nil)
+	if latest, _ := s.GetLatest(); latest != 1 {
+		t.Errorf("latest = %d, want still 1", latest)
+	}
+	if stored, run, _ := l.Begin(2, http.MethodPost, "/api/msgs/aa"); run || stored.Status != http.StatusNotFound {
+		t.Errorf("retry of 2 = %+v, %v, want the recorded 404", stored, run)
+	}
+	if _, run, _ := l.Begin(3, http.MethodPost, "/api/msgs/aa"); !run {
+		t.Errorf("retry of a 500 does not run again")
+	}
+
+	// a claim that outlived ClaimTimeout is taken over
+	now = now.Add(ClaimTimeout + time.Second)
+	if _, run, err := l.Begin(3, http.MethodPost, "/api/msgs/aa"); err != nil || !run {
+		t.Errorf("stale claim: %v, %v, want to run", run, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	s := memstore.New()
+	l := New(s)
+
+	if err := l.Read(4, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Read(5, http.StatusNotFound); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := s.GetLatest(); latest != 4 {
+		t.Errorf("latest = %d, want 4", latest)
+	}
+	// nothing was recorded, the id is still free for a command
+	if _, run, err := l.Begin(4, http.MethodPost, "/api/msgs/aa"); err != nil || !run {
+		t.Errorf("Begin after a read = %v, %v, want to run", run, err)
+	}
+}
+
+func TestMutating(t *testing.T) {
+	for method, want := range map[string]bool{http.MethodGet: false, http.MethodHead: false, http.MethodPost: true, http.MethodPut: true, http.MethodDelete: true} {
+		if got := Mutating(method); got != want {
+			t.Errorf("Mutating(%s) = %v, want %v", method, got, want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package config loads the settings shared by the Go implementations.
+//
+// Values are layered: built-in defaults, then the YAML or TOML file named by
+// MINITWIT_CONFIG (picked by extension), then environment variables. Every
+// field documents its variable in the env tag; empty variables count as unset.
+// Experiment runs change parameters through the compose file or a mounted
+// config file, without rebuilding the image.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/db"
+	"go-minitwit-core/src/password"
+	"go-minitwit-core/src/sessionstore"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+type Config struct {
+	Server     Server     `yaml:"server" toml:"server"`
+	Database   Database   `yaml:"database" toml:"database"`
+	Limits     Limits     `yaml:"limits" toml:"limits"`
+	Passwords  Passwords  `yaml:"passwords" toml:"passwords"`
+	API        API        `yaml:"api" toml:"api"`
+	Sessions   Sessions   `yaml:"sessions" toml:"sessions"`
+	Moderation Moderation `yaml:"moderation" toml:"moderation"`
+	Energy     Energy     `yaml:"energy" toml:"energy"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr" toml:"addr" env:"LISTEN_ADDR"`
+	// GinMode is only used by go-gin: debug, release or test
+	GinMode      string `yaml:"gin_mode" toml:"gin_mode" env:"GIN_MODE"`
+	TemplateGlob string `yaml:"template_glob" toml:"template_glob" env:"TEMPLATE_GLOB"`
+	StaticDir    string `yaml:"static_dir" toml:"static_dir" env:"STATIC_DIR"`
+	// ShutdownTimeout bounds how long in-flight requests may take to drain on
+	// SIGTERM/SIGINT, keep it below the container's stop grace period (10s in Docker)
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+type Database struct {
+	URL string `yaml:"url" toml:"url" env:"DATABASE_URL"`
+	// Migrate applies the pending schema migrations on start-up
+	Migrate bool `yaml:"migrate" toml:"migrate" env:"DB_MIGRATE"`
+
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+
+	PrepareStmt            bool   `yaml:"prepare_stmt" toml:"prepare_stmt" env:"DB_PREPARE_STMT"`
+	QueryExecMode          string `yaml:"query_exec_mode" toml:"query_exec_mode" env:"DB_QUERY_EXEC_MODE"`
+	StatementCacheCapacity int    `yaml:"statement_cache_capacity" toml:"statement_cache_capacity" env:"DB_STATEMENT_CACHE_CAPACITY"`
+
+	// StatsInterval logs the pool statistics periodically, 0 turns it off
+	StatsInterval Duration `yaml:"stats_interval" toml:"stats_interval" env:"DB_POOL_STATS_INTERVAL"`
+}
+
+// Duration reads "30s"-style values from env vars and config files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Limits are the page sizes of the timelines and API lists
+type Limits struct {
+	Timeline int `yaml:"timeline" toml:"timeline" env:"TIMELINE_LIMIT"`
+	API      int `yaml:"api" toml:"api" env:"API_LIMIT"`
+	// APIMax caps the ?no= a client may ask for
+	APIMax    int `yaml:"api_max" toml:"api_max" env:"API_MAX_LIMIT"`
+	Following int `yaml:"following" toml:"following" env:"FOLLOWING_LIMIT"`
+}
+
+type Passwords struct {
+	// Hasher is bcrypt, argon2id, pbkdf2 or plaintext
+	Hasher string `yaml:"hasher" toml:"hasher" env:"PASSWORD_HASHER"`
+}
+
+type API struct {
+	// Credentials is a comma separated user:password list of simulator clients
+	Credentials string `yaml:"credentials" toml:"credentials" env:"SIMULATOR_CREDENTIALS"`
+}
+
+type Sessions struct {
+	// Backend is cookie, memory or postgres
+	Backend string `yaml:"backend" toml:"backend" env:"SESSION_BACKEND"`
+	// Keys are "hashKey[:blockKey]" entries, newest first
+	Keys []string `yaml:"keys" toml:"keys" env:"SESSION_KEYS"`
+}
+
+type Moderation struct {
+	// Moderators are the user ids that may flag and unflag messages. Ids,
+	// unlike usernames, are never handed out again after an account is deleted
+	Moderators []int `yaml:"moderators" toml:"moderators" env:"MODERATORS"`
+}
+
+type Energy struct {
+	// Accounting samples the process CPU time and the RAPL counters around
+	// every request and serves the totals per route at /metrics/energy
+	Accounting bool `yaml:"accounting" toml:"accounting" env:"ENERGY_ACCOUNTING"`
+	// PowercapDir holds the RAPL zones, intel-rapl:<n>/energy_uj
+	PowercapDir string `yaml:"powercap_dir" toml:"powercap_dir" env:"POWERCAP_DIR"`
+}
+
+// DefaultLimits are the page sizes of the reference implementation
+var DefaultLimits = Limits{Timeline: 30, API: 100, APIMax: 1000, Following: 30}
+
+// Default returns the settings the apps used before they were configurable
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":5000",
+			GinMode:         "release",
+			TemplateGlob:    "templates/*.html",
+			StaticDir:       "static",
+			ShutdownTimeout: Duration(8 * time.Second),
+		},
+		Database:  Database{Migrate: true},
+		Limits:    DefaultLimits,
+		Passwords: Passwords{Hasher: password.DefaultHasher},
+		API:       API{Credentials: apiauth.DefaultCredentials},
+		Sessions:  Sessions{Backend: sessionstore.BackendCookie},
+		Energy:    Energy{PowercapDir: "/sys/class/powercap"},
+	}
+}
+
+// Load reads the defaults, the MINITWIT_CONFIG file and the environment, then validates the result
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("MINITWIT_CONFIG"); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if err := loadEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	if len(cfg.Sessions.Keys) == 0 {
+		if secret := os.Getenv("SECRET_KEY"); secret != "" {
+			cfg.Sessions.Keys = []string{secret}
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr (LISTEN_ADDR) must not be empty")
+	check(c.Server.GinMode == "debug" || c.Server.GinMode == "release" || c.Server.GinMode == "test",
+		"server.gin_mode (GIN_MODE) = %q, want debug, release or test", c.Server.GinMode)
+	check(c.Server.TemplateGlob != "", "server.template_glob (TEMPLATE_GLOB) must not be empty")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive")
+	check(c.Database.URL != "", "database.url (DATABASE_URL) must be set")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns (DB_MAX_OPEN_CONNS) = %d, must not be negative", c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime (DB_CONN_MAX_LIFETIME) must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time (DB_CONN_MAX_IDLE_TIME) must not be negative")
+	check(c.Database.QueryExecMode == "" || slices.Contains(db.QueryExecModes, c.Database.QueryExecMode),
+		"database.query_exec_mode (DB_QUERY_EXEC_MODE) = %q, want one of %v", c.Database.QueryExecMode, db.QueryExecModes)
+	check(c.Database.StatementCacheCapacity >= 0, "database.statement_cache_capacity (DB_STATEMENT_CACHE_CAPACITY) = %d, must not be negative", c.Database.StatementCacheCapacity)
+	check(c.Database.StatsInterval >= 0, "database.stats_interval (DB_POOL_STATS_INTERVAL) must not be negative")
+	check(c.Limits.Timeline > 0, "limits.timeline (TIMELINE_LIMIT) = %d, must be positive", c.Limits.Timeline)
+	check(c.Limits.API > 0, "limits.api (API_LIMIT) = %d, must be positive", c.Limits.API)
+	check(c.Limits.APIMax >= c.Limits.API, "limits.api_max (API_MAX_LIMIT) = %d, must be at least limits.api", c.Limits.APIMax)
+	check(c.Limits.Following > 0, "limits.following (FOLLOWING_LIMIT) = %d, must be positive", c.Limits.Following)
+	check(!c.Energy.Accounting || c.Energy.PowercapDir != "", "energy.powercap_dir (POWERCAP_DIR) must not be empty")
+
+	if _, err := password.New(c.Passwords.Hasher); err != nil {
+		errs = append(errs, fmt.Errorf("passwords.hasher (PASSWORD_HASHER): %w", err))
+	}
+	if _, err := apiauth.Parse(c.API.Credentials); err != nil {
+		errs = append(errs, fmt.Errorf("api.credentials (SIMULATOR_CREDENTIALS): %w", err))
+	}
+	switch c.Sessions.Backend {
+	case sessionstore.BackendCookie, sessionstore.BackendMemory, sessionstore.BackendPostgres:
+	default:
+		errs = append(errs, fmt.Errorf("sessions.backend (SESSION_BACKEND) = %q, want cookie, memory or postgres", c.Sessions.Backend))
+	}
+	if len(c.Sessions.Keys) > 0 {
+		if _, err := sessionstore.KeyPairs(c.Sessions.Keys); err != nil {
+			errs = append(errs, fmt.Errorf("sessions.keys (SESSION_KEYS): %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c Config) Pool() db.PoolOptions {
+	return db.PoolOptions{
+		MaxOpenConns:           c.Database.MaxOpenConns,
+		MaxIdleConns:           c.Database.MaxIdleConns,
+		ConnMaxLifetime:        time.Duration(c.Database.ConnMaxLifetime),
+		ConnMaxIdleTime:        time.Duration(c.Database.ConnMaxIdleTime),
+		PrepareStmt:            c.Database.PrepareStmt,
+		QueryExecMode:          c.Database.QueryExecMode,
+		StatementCacheCapacity: c.Database.StatementCacheCapacity,
+	}
+}
+
+// PasswordPolicy returns the hashing policy for new and existing passwords
+func (c Config) PasswordPolicy() (*password.Policy, error) {
+	return password.New(c.Passwords.Hasher)
+}
+
+func (c Config) APIClients() (*apiauth.Authenticator, error) {
+	return apiauth.Parse(c.API.Credentials)
+}
+
+// SessionStore returns the session store settings. Without configured keys it
+// uses fallbackKey, the key the app used to hardcode, and says so in the log.
+func (c Config) SessionStore(fallbackKey string) sessionstore.Config {
+	keys := c.Sessions.Keys
+	if len(keys) == 0 {
+		log.Println("SESSION_KEYS is not set, sessions are signed with the built-in development key")
+		keys = []string{fallbackKey}
+	}
+	return sessionstore.Config{Backend: c.Sessions.Backend, Keys: keys}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	tests := map[string]string{
+		"config.yaml": "server:\n  addr: \":8080\"\ndatabase:\n  conn_max_idle_time: 5m\nlimits:\n  timeline: 50\n  api: 200\nsessions:\n  keys: [new, old]\n",
+		"config.toml": "[server]\naddr = \":8080\"\n[database]\nconn_max_idle_time = \"5m\"\n[limits]\ntimeline = 50\napi = 200\n[sessions]\nkeys = [\"new\", \"old\"]\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("MINITWIT_CONFIG", writeFile(t, name, content))
+			t.Setenv("DATABASE_URL", "postgres://db")
+			t.Setenv("API_LIMIT", "150") // env wins over the file
+			t.Setenv("GIN_MODE", "")     // empty counts as unset
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Default()
+			want.Server.Addr = ":8080"
+			want.Database.URL = "postgres://db"
+			want.Database.ConnMaxIdleTime = Duration(5 * time.Minute)
+			want.Limits = Limits{Timeline: 50, API: 150, APIMax: 1000, Following: 30}
+			want.Sessions.Keys = []string{"new", "old"}
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("Load() =\n%+v\nwant\n%+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestLoadEnvList(t *testing.T) {
+	t.Setenv("MINITWIT_CONFIG", "")
+	t.Setenv("DATABASE_URL", "postgres://db")
+	t.Setenv("SESSION_KEYS", "a, b ,")
+	t.Setenv("MODERATORS", "1, 7")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "90s")
+	t.Setenv("DB_PREPARE_STMT", "true")
+	t.Setenv("SECRET_KEY", "ignored")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Sessions.Keys, []string{"a", "b"}) {
+		t.Errorf("keys = %q, want [a b]", cfg.Sessions.Keys)
+	}
+	if !reflect.DeepEqual(cfg.Moderation.Moderators, []int{1, 7}) {
+		t.Errorf("moderators = %v, want [1 7]", cfg.Moderation.Moderators)
+	}
+	if pool := cfg.Pool(); pool.ConnMaxLifetime != 90*time.Second || !pool.PrepareStmt {
+		t.Errorf("pool = %+v, want 90s lifetime and prepared statements", pool)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("TIMELINE_LIMIT", "0")
+	t.Setenv("PASSWORD_HASHER", "md5")
+	t.Setenv("SESSION_BACKEND", "redis")
+	t.Setenv("DB_QUERY_EXEC_MODE", "fastest")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{"DATABASE_URL", "TIMELINE_LIMIT", "PASSWORD_HASHER", "SESSION_BACKEND", "DB_QUERY_EXEC_MODE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+
+	t.Setenv("TIMELINE_LIMIT", "many")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "TIMELINE_LIMIT") {
+		t.Errorf("non-numeric TIMELINE_LIMIT: err = %v", err)
+	}
+
+	t.Setenv("TIMELINE_LIMIT", "")
+	t.Setenv("MODERATORS", "1,mod")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MODERATORS") {
+		t.Errorf("username in MODERATORS: err = %v", err)
+	}
+	t.Setenv("MODERATORS", "")
+
+	t.Setenv("TIMELINE_LIMIT", "")
+	t.Setenv("MINITWIT_CONFIG", writeFile(t, "config.yaml", "limits:\n  timelime: 10\n"))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "timelime") {
+		t.Errorf("unknown file key: err = %v", err)
+	}
+}
+
+func TestExampleFile(t *testing.T) {
+	t.Setenv("MINITWIT_CONFIG", "../../config.example.yaml")
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,484 @@
+// Package conformance replays the scenarios of tests/test_api_endpoints.py and
+// tests/test_flash_messages.py against a running Minitwit, so every Go
+// implementation can be checked for the same observable behaviour with go test.
+//
+// The suite only talks HTTP. Usernames get a per-run suffix and the ?latest=
+// command ids a per-run offset, so it can be pointed at a database that already
+// contains data and recorded commands from earlier runs.
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SimulatorAuth is the Authorization header sent by the reference simulator
+const SimulatorAuth = "Basic c2ltdWxhdG9yOnN1cGVyX3NhZmUh"
+
+// Run executes the API, error and page scenarios against baseURL (e.g. http://localhost:5000)
+func Run(t *testing.T, baseURL string) {
+	suffix := fmt.Sprintf("%06d", time.Now().UnixNano()%1000000)
+
+	t.Run("API", func(t *testing.T) {
+		RunAPI(t, baseURL, suffix)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		RunErrors(t, baseURL, suffix)
+	})
+	t.Run("Pages", func(t *testing.T) {
+		RunPages(t, baseURL, suffix)
+	})
+}
+
+// RunAPI ports tests/test_api_endpoints.py. The steps depend on each other and
+// stop at the first failure, like the pytest module does.
+func RunAPI(t *testing.T, baseURL string, suffix string) {
+	api := &apiClient{t: t, base: strings.TrimRight(baseURL, "/") + "/api"}
+	if n, err := strconv.Atoi(suffix); err == nil {
+		// the ids the pytest module uses go up to 1337, and all have to fit an INTEGER column
+		api.firstID = n * 2000
+	}
+	aa, bb, cc := "aa"+suffix, "bb"+suffix, "cc"+suffix
+
+	steps := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"register", func(t *testing.T) {
+			api.expectOK(api.post("/register", 1, map[string]string{"username": aa, "email": "a@a.a", "pwd": "a"}))
+			api.expectLatest(1)
+		}},
+		{"register_b", func(t *testing.T) {
+			api.expectOK(api.post("/register", 5, map[string]string{"username": bb, "email": "b@b.b", "pwd": "b"}))
+			api.expectLatest(5)
+		}},
+		{"register_c", func(t *testing.T) {
+			api.expectOK(api.post("/register", 6, map[string]string{"username": cc, "email": "c@c.c", "pwd": "c"}))
+			api.expectLatest(6)
+		}},
+		{"register_duplicate", func(t *testing.T) {
+			resp := api.post("/register", -1, map[string]string{"username": aa, "email": "a@a.a", "pwd": "a"})
+			api.expectStatus(resp, http.StatusBadRequest)
+		}},
+		{"latest", func(t *testing.T) {
+			api.expectOK(api.post("/register", 1337, map[string]string{"username": "test" + suffix, "email": "test@test", "pwd": "foo"}))
+			api.expectLatest(1337)
+		}},
+		{"create_msg", func(t *testing.T) {
+			api.expectOK(api.post("/msgs/"+aa, 2, map[string]string{"content": "Blub!"}))
+			api.expectLatest(2)
+		}},
+		{"get_latest_user_msgs", func(t *testing.T) {
+			msgs := api.getMessages("/msgs/"+aa, 3)
+			if !containsMessage(msgs, "Blub!", aa) {
+				t.Errorf("GET /api/msgs/%s = %v, missing Blub! by %s", aa, msgs, aa)
+			}
+			api.expectLatest(3)
+		}},
+		{"msgs_pub_date", func(t *testing.T) {
+			resp := api.do(http.MethodGet, "/msgs/"+aa+"?date_format=rfc3339", nil, true)
+			api.expectStatus(resp, http.StatusOK)
+
+			var msgs []struct {
+				MessageID      int    `json:"message_id"`
+				PubDate        int64  `json:"pub_date"`
+				PubDateRFC3339 string `json:"pub_date_rfc3339"`
+			}
+			if err := json.Unmarshal(resp.body, &msgs); err != nil || len(msgs) == 0 {
+				t.Fatalf("GET /api/msgs/%s: decoding %q: %v", aa, resp.body, err)
+			}
+			m := msgs[0]
+			published, err := time.Parse(time.RFC3339, m.PubDateRFC3339)
+			if m.MessageID <= 0 || err != nil || published.Unix() != m.PubDate || time.Since(published) > time.Hour {
+				t.Errorf("message = %+v, want an id and matching recent unix and RFC3339 pub_dates", m)
+			}
+		}},
+		{"get_latest_msgs", func(t *testing.T) {
+			msgs := api.getMessages("/msgs", 4)
+			if !containsMessage(msgs, "Blub!", aa) {
+				t.Errorf("GET /api/msgs = %v, missing Blub! by %s", msgs, aa)
+			}
+			api.expectLatest(4)
+		}},
+		{"follow_user", func(t *testing.T) {
+			api.expectOK(api.post("/fllws/"+aa, 7, map[string]string{"follow": bb}))
+			api.expectOK(api.post("/fllws/"+aa, 8, map[string]string{"follow": cc}))
+
+			follows := api.getFollows(aa, 9)
+			if !contains(follows, bb) || !contains(follows, cc) {
+				t.Errorf("follows of %s = %v, want %s and %s", aa, follows, bb, cc)
+			}
+			api.expectLatest(9)
+		}},
+		{"a_unfollows_b", func(t *testing.T) {
+			api.expectOK(api.post("/fllws/"+aa, 10, map[string]string{"unfollow": bb}))
+
+			follows := api.getFollows(aa, 11)
+			if contains(follows, bb) {
+				t.Errorf("follows of %s = %v, still contains %s", aa, follows, bb)
+			}
+			api.expectLatest(11)
+		}},
+		{"unknown_user", func(t *testing.T) {
+			resp := api.do(http.MethodGet, "/msgs/nobody"+suffix, nil, true)
+			api.expectStatus(resp, http.StatusNotFound)
+		}},
+		{"openapi_document", func(t *testing.T) {
+			resp := api.do(http.MethodGet, "/openapi.json", nil, false)
+			api.expectStatus(resp, http.StatusOK)
+
+			var doc struct {
+				OpenAPI string         `json:"openapi"`
+				Paths   map[string]any `json:"paths"`
+			}
+			if err := json.Unmarshal(resp.body, &doc); err != nil || doc.OpenAPI == "" || doc.Paths["/msgs/{username}"] == nil {
+				t.Errorf("GET /api/openapi.json = %.100s, want an OpenAPI document with /msgs/{username}", resp.body)
+			}
+		}},
+		{"requires_simulator_auth", func(t *testing.T) {
+			for _, path := range []string{"/msgs", "/msgs/" + aa, "/fllws/" + aa} {
+				api.expectStatus(api.do(http.MethodGet, path, nil, false), http.StatusForbidden)
+			}
+			body, _ := json.Marshal(map[string]string{"content": "not from the simulator"})
+			api.expectStatus(api.do(http.MethodPost, "/msgs/"+aa, body, false), http.StatusForbidden)
+		}},
+	}
+
+	for _, step := range steps {
+		if !t.Run(step.name, func(t *testing.T) {
+			api.t = t
+			step.run(t)
+		}) {
+			return
+		}
+	}
+}
+
+// RunErrors checks the error bodies of the API byte for byte, every
+// implementation has to answer a failure with the same envelope and code.
+// The requests carry no ?latest= id, so they stay out of the command log.
+func RunErrors(t *testing.T, baseURL string, suffix string) {
+	api := &apiClient{t: t, base: strings.TrimRight(baseURL, "/") + "/api"}
+	user := "err" + suffix
+	api.expectOK(api.post("/register", -1, map[string]string{"username": user, "email": "e@e.e", "pwd": "e"}))
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		simulator bool
+		status    int
+		want      string
+	}{
+		{"unauthorized", http.MethodGet, "/msgs", "", false, http.StatusForbidden,
+			`{"status":403,"error_msg":"You are not authorized to use this resource!","code":"unauthorized"}`},
+		// credentials are checked before the request is validated, anonymous
+		// callers learn nothing about the rules of the API
+		{"unauthorized_invalid_body", http.MethodPost, "/msgs/" + user, `{"content":5}`, false, http.StatusForbidden,
+			`{"status":403,"error_msg":"You are not authorized to use this resource!","code":"unauthorized"}`},
+		{"unauthorized_invalid_query", http.MethodGet, "/msgs?no=many", "", false, http.StatusForbidden,
+			`{"status":403,"error_msg":"You are not authorized to use this resource!","code":"unauthorized"}`},
+		{"unauthorized_invalid_message_id", http.MethodPut, "/flagged/x", "", false, http.StatusForbidden,
+			`{"status":403,"error_msg":"You are not authorized to use this resource!","code":"unauthorized"}`},
+		{"unknown_user", http.MethodGet, "/msgs/nobody" + suffix, "", true, http.StatusNotFound,
+			`{"status":404,"error_msg":"User not found","code":"user_not_found"}`},
+		{"unknown_followed_user", http.MethodPost, "/fllws/" + user, `{"follow":"nobody` + suffix + `"}`, true, http.StatusNotFound,
+			`{"status":404,"error_msg":"User not found","code":"user_not_found"}`},
+		{"malformed_message", http.MethodPost, "/msgs/" + user, `{"content":`, true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Malformed request body","code":"malformed_body"}`},
+		{"follow_without_user", http.MethodPost, "/fllws/" + user, `{}`, true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Invalid request","code":"invalid_request"}`},
+		{"message_not_a_string", http.MethodPost, "/msgs/" + user, `{"content":5}`, true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Invalid request","code":"invalid_request"}`},
+		{"register_without_pwd", http.MethodPost, "/register", `{"username":"x` + suffix + `","email":"x@x.x"}`, true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Invalid request","code":"invalid_request"}`},
+		{"duplicate_username", http.MethodPost, "/register", `{"username":"` + user + `","email":"e@e.e","pwd":"e"}`, true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"The username is already taken","code":"username_taken"}`},
+		{"invalid_limit", http.MethodGet, "/msgs?no=many", "", true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Invalid request","code":"invalid_request"}`},
+		{"invalid_cursor", http.MethodGet, "/msgs?before=x", "", true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Invalid cursor","code":"invalid_cursor"}`},
+		{"invalid_date_format", http.MethodGet, "/msgs?date_format=iso", "", true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Invalid request","code":"invalid_request"}`},
+		{"body_too_large", http.MethodPost, "/msgs/" + user, `{"content":"` + strings.Repeat("x", 20000) + `"}`, true, http.StatusRequestEntityTooLarge,
+			`{"status":413,"error_msg":"Request body too large","code":"body_too_large"}`},
+		{"invalid_message_id", http.MethodPost, "/delete", `{"user":"` + user + `","pwd":"e","message_id":"x"}`, true, http.StatusBadRequest,
+			`{"status":400,"error_msg":"Invalid request","code":"invalid_request"}`},
+		{"delete_wrong_password", http.MethodPost, "/delete", `{"user":"` + user + `","pwd":"x"}`, true, http.StatusForbidden,
+			`{"status":403,"error_msg":"Invalid password","code":"wrong_password"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api.t = t
+			resp := api.do(tt.method, tt.path, []byte(tt.body), tt.simulator)
+			api.expectStatus(resp, tt.status)
+			if string(resp.body) != tt.want {
+				t.Errorf("%s %s: body = %s, want %s", tt.method, tt.path, resp.body, tt.want)
+			}
+			if ct := resp.contentType; ct != "application/json; charset=utf-8" {
+				t.Errorf("%s %s: Content-Type = %q, want application/json; charset=utf-8", tt.method, tt.path, ct)
+			}
+		})
+	}
+}
+
+// RunPages ports tests/test_flash_messages.py: every UI action has to end on a
+// page that shows the matching flash message.
+func RunPages(t *testing.T, baseURL string, suffix string) {
+	base := strings.TrimRight(baseURL, "/")
+	user1, user2 := "user1"+suffix, "user2"+suffix
+	user1Session, user2Session := newBrowser(t), newBrowser(t)
+
+	public := user1Session.get(base + "/public")
+	if public.status != http.StatusOK {
+		t.Fatalf("GET /public: status = %d, want 200", public.status)
+	}
+
+	steps := []struct {
+		name     string
+		expected string
+		run      func() page
+	}{
+		{"register_flash", "You were successfully registered and can login now", func() page {
+			return user1Session.postForm(base+"/register", url.Values{
+				"username": {user1}, "email": {user1 + "@waect.com"}, "password": {"waect"}, "password2": {"waect"},
+			})
+		}},
+		{"login_flash", "You were logged in", func() page {
+			return user1Session.postForm(base+"/login", url.Values{"username": {user1}, "password": {"waect"}})
+		}},
+		{"follow_flash", "You are now following " + user2, func() page {
+			user2Session.postForm(base+"/register", url.Values{
+				"username": {user2}, "email": {user2 + "@waect.com"}, "password": {"waect"}, "password2": {"waect"},
+			})
+			return user1Session.get(base + "/" + user2 + "/follow")
+		}},
+		{"unfollow_flash", "You are no longer following " + user2, func() page {
+			return user1Session.get(base + "/" + user2 + "/unfollow")
+		}},
+		{"post_message_flash", "Your message was recorded", func() page {
+			return user1Session.postForm(base+"/add_message", url.Values{"text": {"Hello, world!"}})
+		}},
+		{"logout_flash", "You were logged out", func() page {
+			return user1Session.get(base + "/logout")
+		}},
+	}
+
+	for _, step := range steps {
+		if !t.Run(step.name, func(t *testing.T) {
+			user1Session.t, user2Session.t = t, t
+			p := step.run()
+			if p.status != http.StatusOK {
+				t.Fatalf("status = %d, want 200", p.status)
+			}
+			if !strings.Contains(p.body, step.expected) {
+				t.Errorf("page %s does not show %q", p.url, step.expected)
+			}
+		}) {
+			return
+		}
+	}
+}
+
+type apiClient struct {
+	t    *testing.T
+	base string
+	// firstID is added to the command ids, a retried id is answered from the command log
+	firstID int
+}
+
+// id is the ?latest= value for the scenario's command id, negative ones are left out anyway
+func (a *apiClient) id(latest int) int {
+	if latest < 0 {
+		return latest
+	}
+	return a.firstID + latest
+}
+
+type response struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+func (a *apiClient) do(method string, path string, body []byte, simulator bool) response {
+	a.t.Helper()
+
+	req, err := http.NewRequest(method, a.base+path, bytes.NewReader(body))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	req.Header.Set("Connection", "close")
+	req.Header.Set("Content-Type", "application/json")
+	if simulator {
+		req.Header.Set("Authorization", SimulatorAuth)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatalf("%s %s: reading body: %v", method, path, err)
+	}
+	return response{status: resp.StatusCode, contentType: resp.Header.Get("Content-Type"), body: respBody}
+}
+
+// post sends data as JSON, latest < 0 leaves out the latest parameter
+func (a *apiClient) post(path string, latest int, data any) response {
+	a.t.Helper()
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.do(http.MethodPost, withLatest(path, a.id(latest), 0), body, true)
+}
+
+func (a *apiClient) getMessages(path string, latest int) []map[string]any {
+	a.t.Helper()
+
+	resp := a.do(http.MethodGet, withLatest(path, a.id(latest), 20), nil, true)
+	a.expectStatus(resp, http.StatusOK)
+
+	var msgs []map[string]any
+	if err := json.Unmarshal(resp.body, &msgs); err != nil {
+		a.t.Fatalf("GET %s: decoding %q: %v", path, resp.body, err)
+	}
+	return msgs
+}
+
+func (a *apiClient) getFollows(userName string, latest int) []string {
+	a.t.Helper()
+
+	resp := a.do(http.MethodGet, withLatest("/fllws/"+userName, a.id(latest), 20), nil, true)
+	a.expectOK(resp)
+
+	var body struct {
+		Follows []string `json:"follows"`
+	}
+	if err := json.Unmarshal(resp.body, &body); err != nil {
+		a.t.Fatalf("GET /fllws/%s: decoding %q: %v", userName, resp.body, err)
+	}
+	return body.Follows
+}
+
+func (a *apiClient) expectLatest(want int) {
+	a.t.Helper()
+
+	resp := a.do(http.MethodGet, "/latest", nil, true)
+	a.expectOK(resp)
+
+	var body struct {
+		Latest int `json:"latest"`
+	}
+	if err := json.Unmarshal(resp.body, &body); err != nil {
+		a.t.Fatalf("GET /latest: decoding %q: %v", resp.body, err)
+	}
+	if body.Latest != a.id(want) {
+		a.t.Errorf("latest = %d, want %d", body.Latest, a.id(want))
+	}
+}
+
+// expectOK mirrors requests' response.ok
+func (a *apiClient) expectOK(resp response) {
+	a.t.Helper()
+	if resp.status >= 400 {
+		a.t.Fatalf("status = %d, want < 400 (body %q)", resp.status, resp.body)
+	}
+}
+
+func (a *apiClient) expectStatus(resp response, want int) {
+	a.t.Helper()
+	if resp.status != want {
+		a.t.Errorf("status = %d, want %d (body %q)", resp.status, want, resp.body)
+	}
+}
+
+func withLatest(path string, latest int, no int) string {
+	q := url.Values{}
+	if no > 0 {
+		q.Set("no", fmt.Sprint(no))
+	}
+	if latest >= 0 {
+		q.Set("latest", fmt.Sprint(latest))
+	}
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+func containsMessage(msgs []map[string]any, content string, user string) bool {
+	for _, m := range msgs {
+		if m["content"] == content && m["user"] == user {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// browser is a cookie-keeping client that follows redirects, like requests.Session
+type browser struct {
+	t      *testing.T
+	client *http.Client
+}
+
+type page struct {
+	url    string
+	status int
+	body   string
+}
+
+func newBrowser(t *testing.T) *browser {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &browser{t: t, client: &http.Client{Jar: jar}}
+}
+
+func (b *browser) get(u string) page {
+	resp, err := b.client.Get(u)
+	return b.read(u, resp, err)
+}
+
+func (b *browser) postForm(u string, data url.Values) page {
+	resp, err := b.client.PostForm(u, data)
+	return b.read(u, resp, err)
+}
+
+func (b *browser) read(u string, resp *http.Response, err error) page {
+	if err != nil {
+		b.t.Fatalf("%s: %v", u, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		b.t.Fatalf("%s: reading body: %v", u, err)
+	}
+	return page{url: resp.Request.URL.String(), status: resp.StatusCode, body: string(body)}
+}
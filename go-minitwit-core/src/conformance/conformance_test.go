@@ -0,0 +1,17 @@
+package conformance
+
+import (
+	"os"
+	"testing"
+)
+
+// TestRunningServer checks a deployed Minitwit, e.g.
+//
+//	MINITWIT_BASE_URL=http://localhost:5000 go test ./src/conformance/...
+func TestRunningServer(t *testing.T) {
+	baseURL := os.Getenv("MINITWIT_BASE_URL")
+	if baseURL == "" {
+		t.Skip("MINITWIT_BASE_URL is not set")
+	}
+	Run(t, baseURL)
+}
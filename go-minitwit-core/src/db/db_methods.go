@@ -0,0 +1,486 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/store"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// GormStore is the store.Store implementation backed by gorm
+type GormStore struct {
+	DB *gorm.DB
+}
+
+var _ store.Store = (*GormStore)(nil)
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{DB: db}
+}
+
+func CheckValueInMap(maps []map[interface{}]interface{}, value interface{}) bool {
+	for _, m := range maps {
+		for _, v := range m {
+			if v == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ConnectDB opens Postgres, or SQLite when uri starts with "sqlite:"
+func ConnectDB(uri string, pool PoolOptions) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	if path, ok := sqlitePath(uri); ok {
+		dialector = openSQLite(path, &pool)
+	} else {
+		dsn, err := pool.dsn(uri)
+		if err != nil {
+			return nil, err
+		}
+		dialector = postgres.Open(dsn)
+	}
+
+	// Create a new GORM database connection with silent logging
+	db, err := gorm.Open(dialector, &gorm.Config{
+		PrepareStmt: pool.PrepareStmt,
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true, // Use singular table names
+		},
+		Logger: logger.New(
+			log.New(os.Stdout, "\r\n", log.LstdFlags), // Create a logger but silence it
+			logger.Config{
+				LogLevel: logger.Silent, // Set log level to Silent
+			},
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := pool.apply(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// translate maps the driver's constraint violations onto gorm.ErrDuplicatedKey
+// and friends, other errors come back unchanged
+func (s *GormStore) translate(err error) error {
+	if translator, ok := s.DB.Dialector.(gorm.ErrorTranslator); ok {
+		return translator.Translate(err)
+	}
+	return err
+}
+
+// userError keeps err and adds store.ErrUserNotFound when it says there is no such user
+func (s *GormStore) userError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(s.translate(err), gorm.ErrForeignKeyViolated) {
+		return fmt.Errorf("%w: %w", store.ErrUserNotFound, err)
+	}
+	return err
+}
+
+// Fetches a username by their ID
+func (s *GormStore) GetUserNameByUserID(userID int) (string, error) {
+	var user models.Users
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return "", fmt.Errorf("getting user %d: %w", userID, s.userError(err))
+	}
+	return user.Username, nil
+}
+
+// fetches a user by their ID
+func (s *GormStore) GetUserIDByUsername(userName string) (int, error) {
+	user, err := s.GetUserByUsername(userName)
+	if err != nil {
+		return -1, err
+	}
+	return user.UserID, nil
+}
+
+func (s *GormStore) GetUserByUsername(userName string) (models.Users, error) {
+	var user models.Users
+	if err := s.DB.Where("username = ?", userName).First(&user).Error; err != nil {
+		return user, fmt.Errorf("getting user %s: %w", userName, s.userError(err))
+	}
+	return user, nil
+}
+
+func (s *GormStore) GetPublicMessages(page store.Page) ([]models.MessageUser, error) {
+
+	var messages []models.MessageUser
+	// Ensure only the required fields are selected
+	query, reverse := paginate(s.DB.Table("messages").
+		Select("messages.message_id, messages.author_id, messages.text, messages.pub_date, messages.flagged, users.user_id, users.username, users.email").
+		Joins("JOIN users ON messages.author_id = users.user_id").
+		Where("messages.flagged = ?", 0), page, true)
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("getting public messages: %w", err)
+	}
+	if reverse {
+		slices.Reverse(messages)
+	}
+	return messages, nil
+}
+
+// registers a new user
+func (s *GormStore) RegisterUser(userName string, email string, password string) error {
+
+	newUser := models.Users{
+		Username: userName,
+		Email:    email,
+		Pwd:      password,
+	}
+
+	// one statement against the unique index, a concurrent registration of the
+	// same name either wins or makes this insert a no-op
+	result := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "username"}},
+		DoNothing: true,
+	}).Create(&newUser)
+	if err := result.Error; err != nil {
+		if errors.Is(s.translate(err), gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("registering %s: %w: %w", userName, store.ErrDuplicateUsername, err)
+		}
+		return fmt.Errorf("registering %s: %w", userName, err)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("registering %s: %w", userName, store.ErrDuplicateUsername)
+	}
+	return nil
+}
+
+// replaces the stored password, used to upgrade the hash format on login
+func (s *GormStore) UpdatePassword(userID int, pwHash string) error {
+	result := s.DB.Model(&models.Users{}).Where("user_id = ?", userID).Update("pw_hash", pwHash)
+	if result.Error != nil {
+		return fmt.Errorf("updating password of user %d: %w", userID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("updating password of user %d: %w", userID, store.ErrUserNotFound)
+	}
+	return nil
+}
+
+// myTimelineAuthors is the user and everyone they follow, joined against
+// messages so the timeline is one query that walks messages(author_id, pub_date)
+const myTimelineAuthors = "JOIN (SELECT CAST(? AS INTEGER) AS author_id UNION SELECT whom_id FROM followers WHERE who_id = ?) AS authors ON messages.author_id = authors.author_id"
+
+// fetches a page of the messages for the current logged in user for 'My Timeline'
+func (s *GormStore) GetMyMessages(userID int, page store.Page) ([]models.MessageUser, error) {
+	var messages []models.MessageUser
+
+	query, reverse := myMessagesQuery(s.DB, userID, page)
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("getting timeline of user %d: %w", userID, err)
+	}
+	if reverse {
+		slices.Reverse(messages)
+	}
+	return messages, nil
+}
+
+func myMessagesQuery(db *gorm.DB, userID int, page store.Page) (*gorm.DB, bool) {
+	return paginate(db.Table("messages").
+		Select("messages.*, users.*").
+		Joins(myTimelineAuthors, userID, userID).
+		Joins("JOIN users ON messages.author_id = users.user_id").
+		Where("messages.flagged = ?", 0), page, true)
+}
+
+// getFollowing fetches up to `limit` users that the user identified by userID is following
+func (s *GormStore) GetFollowing(userID int, limit int) ([]models.Users, error) {
+	var users []models.Users
+	err := s.DB.
+		Select("users.*").
+		Joins("INNER JOIN followers ON users.user_id = followers.whom_id").
+		Where("followers.who_id = ?", userID).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting users followed by %d: %w", userID, err)
+	}
+	return users, nil
+}
+
+// adds a new message to the database
+func (s *GormStore) AddMessage(text string, author_id int) error {
+	currentTime, err := time.Parse(time.RFC3339, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error formatting time: %v", err)
+	}
+
+	newMessage := models.Messages{
+		AuthorID: author_id,
+		Content:  text,
+		PubDate:  currentTime,
+		Flagged:  0,
+	}
+
+	if err := s.DB.Create(&newMessage).Error; err != nil {
+		return fmt.Errorf("adding message by user %d: %w", author_id, s.userError(err))
+	}
+	return nil
+}
+
+// followUser adds a new follower to the database
+func (s *GormStore) FollowUser(userID int, profileUserID int) error {
+	var count int64
+	err := s.DB.Model(&models.Followers{}).Where("who_id = ? AND whom_id = ?", userID, profileUserID).Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("following user %d: %w", profileUserID, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	newFollower := models.Followers{
+		WhoID:  userID,
+		WhomID: profileUserID,
+	}
+	if err := s.DB.Create(&newFollower).Error; err != nil {
+		return fmt.Errorf("following user %d: %w", profileUserID, s.userError(err))
+	}
+	return nil
+}
+
+// unfollowUser removes a follower from the database
+func (s *GormStore) UnfollowUser(userID int, profileUserID int) error {
+	err := s.DB.Where("who_id = ? AND whom_id = ?", userID, profileUserID).Delete(&models.Followers{}).Error
+	if err != nil {
+		return fmt.Errorf("unfollowing user %d: %w", profileUserID, err)
+	}
+	return nil
+}
+
+// DeleteMessage removes a message of authorID together with its moderation log entries
+func (s *GormStore) DeleteMessage(messageID int, authorID int) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Messages{}).Where("message_id = ? AND author_id = ?", messageID, authorID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return store.ErrMessageNotFound
+		}
+		if err := tx.Where("message_id = ?", messageID).Delete(&models.ModerationLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("message_id = ?", messageID).Delete(&models.Messages{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("deleting message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// DeleteUser removes a user and every row that refers to them in one transaction,
+// children first so the foreign keys hold at every step
+func (s *GormStore) DeleteUser(userID int) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		authored := tx.Model(&models.Messages{}).Select("message_id").Where("author_id = ?", userID)
+		steps := []*gorm.DB{
+			tx.Where("message_id IN (?) OR moderator_id = ?", authored, userID).Delete(&models.ModerationLog{}),
+			tx.Where("who_id = ? OR whom_id = ?", userID, userID).Delete(&models.Followers{}),
+			tx.Where("author_id = ?", userID).Delete(&models.Messages{}),
+		}
+		for _, step := range steps {
+			if step.Error != nil {
+				return step.Error
+			}
+		}
+		result := tx.Delete(&models.Users{}, userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return store.ErrUserNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("deleting user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// fetches all messages from picked user
+func (s *GormStore) GetUserMessages(pUserId int, page store.Page) ([]models.MessageUser, error) {
+	var messages []models.MessageUser
+	query, reverse := paginate(s.DB.Table("messages").
+		Select("messages.*, users.*").
+		Joins("JOIN users ON users.user_id = messages.author_id").
+		Where("messages.flagged = ? AND users.user_id = ?", 0, pUserId), page, false)
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("getting messages of user %d: %w", pUserId, err)
+	}
+	if reverse {
+		slices.Reverse(messages)
+	}
+
+	return messages, nil
+}
+
+// SetFlagged flags or unflags a message and logs who did it in the same transaction
+func (s *GormStore) SetFlagged(messageID int, flagged bool, moderatorID int) error {
+	value := 0
+	if flagged {
+		value = 1
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Messages{}).Where("message_id = ?", messageID).Update("flagged", value)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return store.ErrMessageNotFound
+		}
+		return tx.Create(&models.ModerationLog{
+			MessageID:   messageID,
+			ModeratorID: moderatorID,
+			Flagged:     value,
+			CreatedAt:   time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("setting flagged of message %d: %w", messageID, s.userError(err))
+	}
+	return nil
+}
+
+// flaggedBy joins the newest flag entry of every message and the moderator
+// behind it, messages flagged outside the moderation log have neither
+const flaggedBy = "LEFT JOIN moderation_log AS last_flag ON last_flag.id = (SELECT MAX(id) FROM moderation_log WHERE moderation_log.message_id = messages.message_id AND moderation_log.flagged = 1) " +
+	"LEFT JOIN users AS moderators ON moderators.user_id = last_flag.moderator_id"
+
+// fetches up to limit flagged messages, the most recently flagged first
+func (s *GormStore) GetFlaggedMessages(limit int) ([]models.FlaggedMessage, error) {
+	var messages []models.FlaggedMessage
+	err := s.DB.Table("messages").
+		Select("messages.*, users.*, moderators.username AS flagged_by, last_flag.created_at AS flagged_at").
+		Joins("JOIN users ON messages.author_id = users.user_id").
+		Joins(flaggedBy).
+		Where("messages.flagged <> ?", 0).
+		Order("COALESCE(last_flag.id, 0) DESC, messages.message_id DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("getting flagged messages: %w", err)
+	}
+	return messages, nil
+}
+
+// paginate orders a messages query and restricts it to page. Pages next to a
+// cursor are read walking away from it, so for one direction the rows come back
+// in the opposite of the display order and reverse is true.
+func paginate(query *gorm.DB, page store.Page, newestFirst bool) (paged *gorm.DB, reverse bool) {
+	const (
+		asc  = "messages.pub_date ASC, messages.message_id ASC"
+		desc = "messages.pub_date DESC, messages.message_id DESC"
+	)
+
+	switch {
+	case page.Before != nil:
+		query = query.Where("(messages.pub_date, messages.message_id) < (?, ?)", page.Before.PubDate, page.Before.MessageID).Order(desc)
+		reverse = !newestFirst
+	case page.After != nil:
+		query = query.Where("(messages.pub_date, messages.message_id) > (?, ?)", page.After.PubDate, page.After.MessageID).Order(asc)
+		reverse = newestFirst
+	case newestFirst:
+		query = query.Order(desc)
+	default:
+		query = query.Order(asc)
+	}
+	return query.Limit(page.Limit), reverse
+}
+
+// GetLatest is -1 until the simulator sent its first command
+func (s *GormStore) GetLatest() (int, error) {
+	var latest models.Latest
+	err := s.DB.Where("id = 1").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("getting latest: %w", err)
+	}
+	return latest.Value, nil
+}
+
+func (s *GormStore) UpdateLatest(commandID int) error {
+	if err := s.DB.Save(&models.Latest{ID: 1, Value: commandID}).Error; err != nil {
+		return fmt.Errorf("updating latest: %w", err)
+	}
+	return nil
+}
+
+// ClaimCommand marks a simulator command as running, the insert decides
+// between concurrent retries of the same id
+func (s *GormStore) ClaimCommand(cmd models.Command, staleBefore time.Time) (models.Command, bool, error) {
+	cmd.Status = 0
+	result := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "command_id"}},
+		DoNothing: true,
+	}).Create(&cmd)
+	if result.Error != nil {
+		return cmd, false, fmt.Errorf("claiming command %d: %w", cmd.CommandID, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return cmd, true, nil
+	}
+
+	// a claim whose request never finished, e.g. because the app was stopped
+	result = s.DB.Model(&models.Command{}).
+		Where("command_id = ? AND status = 0 AND created_at < ?", cmd.CommandID, staleBefore).
+		Updates(map[string]any{"method": cmd.Method, "path": cmd.Path, "created_at": cmd.CreatedAt})
+	if result.Error != nil {
+		return cmd, false, fmt.Errorf("claiming command %d: %w", cmd.CommandID, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return cmd, true, nil
+	}
+
+	var stored models.Command
+	if err := s.DB.First(&stored, "command_id = ?", cmd.CommandID).Error; err != nil {
+		return cmd, false, fmt.Errorf("getting command %d: %w", cmd.CommandID, err)
+	}
+	return stored, false, nil
+}
+
+// CompleteCommand stores the outcome of a command and moves latest on in one transaction
+func (s *GormStore) CompleteCommand(cmd models.Command, latest bool) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&cmd).Error; err != nil {
+			return err
+		}
+		if !latest {
+			return nil
+		}
+		return tx.Save(&models.Latest{ID: 1, Value: cmd.CommandID}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("completing command %d: %w", cmd.CommandID, err)
+	}
+	return nil
+}
+
+// ReleaseCommand forgets a running command, finished ones stay
+func (s *GormStore) ReleaseCommand(commandID int) error {
+	err := s.DB.Where("command_id = ? AND status = 0", commandID).Delete(&models.Command{}).Error
+	if err != nil {
+		return fmt.Errorf("releasing command %d: %w", commandID, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PoolOptions tunes the database/sql pool and how statements are sent to
+// Postgres. The zero value keeps the database/sql and pgx defaults.
+type PoolOptions struct {
+	// MaxOpenConns 0 means no limit
+	MaxOpenConns int
+	// MaxIdleConns 0 keeps the database/sql default of 2, a negative value keeps no idle connections
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// PrepareStmt makes gorm prepare every query once per connection and reuse it
+	PrepareStmt bool
+	// QueryExecMode is pgx's default_query_exec_mode: cache_statement, cache_describe,
+	// describe_exec, exec or simple_protocol
+	QueryExecMode string
+	// StatementCacheCapacity is the number of statements pgx caches per connection
+	StatementCacheCapacity int
+}
+
+// QueryExecModes are the values pgx accepts for QueryExecMode
+var QueryExecModes = []string{"cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol"}
+
+// dsn adds the pgx statement settings to uri, which may be a URL or a key=value string
+func (o PoolOptions) dsn(uri string) (string, error) {
+	params := [][2]string{}
+	if o.QueryExecMode != "" {
+		params = append(params, [2]string{"default_query_exec_mode", o.QueryExecMode})
+	}
+	if o.StatementCacheCapacity > 0 {
+		params = append(params, [2]string{"statement_cache_capacity", fmt.Sprint(o.StatementCacheCapacity)})
+	}
+	if len(params) == 0 {
+		return uri, nil
+	}
+
+	if strings.HasPrefix(uri, "postgres://") || strings.HasPrefix(uri, "postgresql://") {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return "", fmt.Errorf("parsing database url: %w", err)
+		}
+		q := u.Query()
+		for _, p := range params {
+			q.Set(p[0], p[1])
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	for _, p := range params {
+		uri += " " + p[0] + "=" + p[1]
+	}
+	return strings.TrimSpace(uri), nil
+}
+
+func (o PoolOptions) apply(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(o.MaxOpenConns)
+	if o.MaxIdleConns != 0 {
+		sqlDB.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(o.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+	return nil
+}
+
+// PoolStats is a snapshot of sql.DBStats, the wait and close counters are totals since start-up
+type PoolStats struct {
+	MaxOpen           int           `json:"max_open"`
+	Open              int           `json:"open"`
+	InUse             int           `json:"in_use"`
+	Idle              int           `json:"idle"`
+	WaitCount         int64         `json:"wait_count"`
+	WaitDuration      time.Duration `json:"wait_duration_ns"`
+	MaxIdleClosed     int64         `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64         `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64         `json:"max_lifetime_closed"`
+}
+
+func (p PoolStats) String() string {
+	return fmt.Sprintf("open=%d/%d in_use=%d idle=%d wait_count=%d wait=%s closed(idle=%d idle_time=%d lifetime=%d)",
+		p.Open, p.MaxOpen, p.InUse, p.Idle, p.WaitCount, p.WaitDuration,
+		p.MaxIdleClosed, p.MaxIdleTimeClosed, p.MaxLifetimeClosed)
+}
+
+// Stats reports the current state of the connection pool behind db
+func Stats(db *gorm.DB) (PoolStats, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return PoolStats{}, err
+	}
+	s := sqlDB.Stats()
+	return PoolStats{
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDuration:      s.WaitDuration,
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}, nil
+}
+
+// Close closes the connection pool once the queries that are still running have finished
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// LogStats logs the pool statistics every interval until ctx is done, so they
+// can be lined up with the timestamps of an energy measurement
+func LogStats(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := Stats(db)
+			if err != nil {
+				log.Println("db pool stats:", err)
+				return
+			}
+			log.Println("db pool:", stats)
+		}
+	}
+}
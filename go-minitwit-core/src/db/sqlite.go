@@ -0,0 +1,43 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// SQLiteMemory is the path of an in-memory SQLite database
+const SQLiteMemory = ":memory:"
+
+// sqlitePath reports whether uri selects SQLite: "sqlite:<path>", "sqlite://<path>"
+// or "sqlite::memory:". Relative paths are relative to the working directory.
+func sqlitePath(uri string) (string, bool) {
+	path, ok := strings.CutPrefix(uri, "sqlite:")
+	if !ok {
+		return "", false
+	}
+	if p, ok := strings.CutPrefix(path, "//"); ok {
+		path = p
+	}
+	return path, true
+}
+
+// openSQLite turns on what Postgres does by default: foreign keys, and waiting
+// for a lock instead of failing. An in-memory database lives and dies with its
+// connection, so the pool is pinned to exactly one.
+func openSQLite(path string, pool *PoolOptions) gorm.Dialector {
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path == SQLiteMemory {
+		pool.MaxOpenConns = 1
+		pool.MaxIdleConns = 1
+		pool.ConnMaxLifetime = 0
+		pool.ConnMaxIdleTime = 0
+	} else {
+		pragmas += "&_pragma=journal_mode(WAL)"
+	}
+	if strings.Contains(path, "?") {
+		return sqlite.Open(path + "&" + pragmas)
+	}
+	return sqlite.Open(path + "?" + pragmas)
+}
@@ -0,0 +1,340 @@
+package db_test
+
+import (
+	"errors"
+	"go-minitwit-core/src/db"
+	"go-minitwit-core/src/migrate"
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/store"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newSQLiteStore is a migrated in-memory database, the same schema the apps run on
+func newSQLiteStore(t *testing.T) *db.GormStore {
+	t.Helper()
+
+	gormDB, err := db.ConnectDB("sqlite::memory:", db.PoolOptions{MaxIdleConns: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close(gormDB) })
+	if _, err := migrate.Up(gormDB); err != nil {
+		t.Fatal(err)
+	}
+	return db.NewGormStore(gormDB)
+}
+
+func texts(messages []models.MessageUser) string {
+	var t []string
+	for _, m := range messages {
+		t = append(t, m.Text)
+	}
+	return strings.Join(t, " ")
+}
+
+func TestGormStoreOnSQLite(t *testing.T) {
+	s := newSQLiteStore(t)
+
+	for _, name := range []string{"aa", "bb", "cc"} {
+		if err := s.RegisterUser(name, name+"@example.com", "pwd"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aa, _ := s.GetUserIDByUsername("aa")
+	bb, _ := s.GetUserIDByUsername("bb")
+	cc, _ := s.GetUserIDByUsername("cc")
+	if aa == -1 || bb == -1 || cc == -1 {
+		t.Fatalf("user ids = %d %d %d", aa, bb, cc)
+	}
+
+	// messages added within the same second share a pub_date, message_id keeps them apart
+	for _, m := range []struct {
+		text   string
+		author int
+	}{{"a1", aa}, {"b1", bb}, {"c1", cc}, {"a2", aa}, {"b2", bb}} {
+		if err := s.AddMessage(m.text, m.author); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.FollowUser(aa, bb); err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := s.GetPublicMessages(store.FirstPage(2))
+	if err != nil || texts(public) != "b2 a2" {
+		t.Fatalf("public first page = %q, %v, want b2 a2", texts(public), err)
+	}
+	older := &store.Cursor{PubDate: public[1].PubDate, MessageID: public[1].MessageID}
+	if next, err := s.GetPublicMessages(store.Page{Limit: 2, Before: older}); err != nil || texts(next) != "c1 b1" {
+		t.Errorf("public older page = %q, %v, want c1 b1", texts(next), err)
+	}
+	if prev, err := s.GetPublicMessages(store.Page{Limit: 1, After: older}); err != nil || texts(prev) != "b2" {
+		t.Errorf("public newer page = %q, %v, want b2", texts(prev), err)
+	}
+
+	mine, err := s.GetMyMessages(aa, store.FirstPage(-1))
+	if err != nil || texts(mine) != "b2 a2 b1 a1" {
+		t.Errorf("my timeline = %q, %v, want b2 a2 b1 a1", texts(mine), err)
+	}
+	if mine, _ := s.GetMyMessages(aa, store.Page{Limit: 2, Before: older}); texts(mine) != "b1 a1" {
+		t.Errorf("my timeline older page = %q, want b1 a1", texts(mine))
+	}
+
+	user, err := s.GetUserMessages(bb, store.FirstPage(10))
+	if err != nil || texts(user) != "b1 b2" || user[0].Username != "bb" {
+		t.Errorf("user timeline = %+v, %v, want b1 b2 by bb", user, err)
+	}
+	if time.Since(user[0].PubDate) > time.Minute {
+		t.Errorf("pub_date = %v, want about now", user[0].PubDate)
+	}
+
+	following, err := s.GetFollowing(aa, 10)
+	if err != nil || len(following) != 1 || following[0].Username != "bb" {
+		t.Errorf("following = %+v, %v, want [bb]", following, err)
+	}
+	if err := s.UnfollowUser(aa, bb); err != nil {
+		t.Fatal(err)
+	}
+	if following, _ := s.GetFollowing(aa, 10); len(following) != 0 {
+		t.Errorf("following after unfollow = %+v", following)
+	}
+
+	if err := s.UpdateLatest(42); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := s.GetLatest(); latest != 42 {
+		t.Errorf("latest = %d, want 42", latest)
+	}
+}
+
+func TestSQLiteForeignKeys(t *testing.T) {
+	s := newSQLiteStore(t)
+
+	if err := s.DB.Create(&models.Messages{AuthorID: 99, Content: "orphan", PubDate: time.Now()}).Error; err == nil {
+		t.Error("message by an unknown author was stored, foreign keys are off")
+	}
+}
+
+func TestGormStoreErrors(t *testing.T) {
+	s := newSQLiteStore(t)
+	if err := s.RegisterUser("aa", "aa@example.com", "pwd"); err != nil {
+		t.Fatal(err)
+	}
+	aa, _ := s.GetUserIDByUsername("aa")
+
+	if id, err := s.GetUserIDByUsername("nobody"); id != -1 || !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUserIDByUsername(nobody) = %d, %v, want -1, ErrUserNotFound", id, err)
+	}
+	if _, err := s.GetUserByUsername("nobody"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUserByUsername(nobody) error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := s.GetUserNameByUserID(42); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUserNameByUserID(42) error = %v, want ErrUserNotFound", err)
+	}
+	if err := s.FollowUser(aa, 42); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("FollowUser(aa, 42) error = %v, want ErrUserNotFound", err)
+	}
+	if err := s.AddMessage("orphan", 42); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("AddMessage by 42 error = %v, want ErrUserNotFound", err)
+	}
+	if err := s.UpdatePassword(42, "x"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("UpdatePassword(42) error = %v, want ErrUserNotFound", err)
+	}
+
+	// a failing statement is reported, not swallowed
+	if err := s.DB.Exec("DROP TABLE followers").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetFollowing(aa, 10); err == nil || errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetFollowing without a followers table error = %v", err)
+	}
+}
+
+func TestConcurrentRegistration(t *testing.T) {
+	// a file, unlike :memory:, gives every goroutine a connection of its own
+	gormDB, err := db.ConnectDB("sqlite:"+filepath.Join(t.TempDir(), "minitwit.db"), db.PoolOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close(gormDB) })
+	if _, err := migrate.Up(gormDB); err != nil {
+		t.Fatal(err)
+	}
+	s := db.NewGormStore(gormDB)
+
+	const parallel = 16
+	errs := make(chan error, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.RegisterUser("same", "same@example.com", "pwd")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	registered := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			registered++
+		case !errors.Is(err, store.ErrDuplicateUsername):
+			t.Errorf("RegisterUser error = %v, want ErrDuplicateUsername", err)
+		}
+	}
+	var count int64
+	if err := gormDB.Model(&models.Users{}).Where("username = ?", "same").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if registered != 1 || count != 1 {
+		t.Errorf("%d registrations succeeded and %d rows stored, want 1 and 1", registered, count)
+	}
+}
+
+func TestGormStoreModeration(t *testing.T) {
+	s := newSQLiteStore(t)
+	for _, name := range []string{"mod", "aa"} {
+		if err := s.RegisterUser(name, name+"@example.com", "pwd"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mod, _ := s.GetUserIDByUsername("mod")
+	aa, _ := s.GetUserIDByUsername("aa")
+	for _, text := range []string{"a1", "a2", "a3", "a4"} {
+		if err := s.AddMessage(text, aa); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []int{2, 1, 3} {
+		if err := s.SetFlagged(id, true, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetFlagged(3, false, mod); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetFlagged(99, true, mod); !errors.Is(err, store.ErrMessageNotFound) {
+		t.Errorf("SetFlagged(99) error = %v, want ErrMessageNotFound", err)
+	}
+	if err := s.SetFlagged(3, true, 42); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("SetFlagged by 42 error = %v, want ErrUserNotFound", err)
+	}
+	// flagged outside the moderation log
+	if err := s.DB.Exec("UPDATE messages SET flagged = 1 WHERE message_id IN (3, 4)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	flagged, err := s.GetFlaggedMessages(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range flagged {
+		got = append(got, m.Text+" "+m.FlaggedBy)
+		if m.FlaggedBy != "" && time.Since(m.FlaggedAt) > time.Minute {
+			t.Errorf("%s flagged at %v, want about now", m.Text, m.FlaggedAt)
+		}
+	}
+	if want := "a3 mod,a1 mod,a2 mod,a4 "; strings.Join(got, ",") != want {
+		t.Errorf("flagged = %q, want %q", strings.Join(got, ","), want)
+	}
+	if public, _ := s.GetPublicMessages(store.FirstPage(10)); len(public) != 0 {
+		t.Errorf("public timeline = %q, want the flagged messages hidden", texts(public))
+	}
+	if user, _ := s.GetUserMessages(aa, store.FirstPage(10)); len(user) != 0 {
+		t.Errorf("user timeline = %q, want the flagged messages hidden", texts(user))
+	}
+
+	var entries int64
+	s.DB.Model(&models.ModerationLog{}).Count(&entries)
+	if entries != 4 {
+		t.Errorf("%d moderation log entries, want 4", entries)
+	}
+}
+
+func TestGormStoreDelete(t *testing.T) {
+	s := newSQLiteStore(t)
+	for _, name := range []string{"aa", "bb"} {
+		if err := s.RegisterUser(name, name+"@example.com", "pwd"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aa, _ := s.GetUserIDByUsername("aa")
+	bb, _ := s.GetUserIDByUsername("bb")
+	for _, m := range []struct {
+		text   string
+		author int
+	}{{"a1", aa}, {"a2", aa}, {"b1", bb}, {"b2", bb}} {
+		if err := s.AddMessage(m.text, m.author); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.FollowUser(aa, bb)
+	_ = s.FollowUser(bb, aa)
+	// log entries pointing at aa as author and as moderator, and at b2
+	_ = s.SetFlagged(1, true, bb)
+	_ = s.SetFlagged(3, true, aa)
+	_ = s.SetFlagged(4, true, bb)
+
+	if err := s.DeleteMessage(4, aa); !errors.Is(err, store.ErrMessageNotFound) {
+		t.Errorf("DeleteMessage of another author: err = %v, want ErrMessageNotFound", err)
+	}
+	if err := s.DeleteMessage(4, bb); err != nil {
+		t.Fatalf("DeleteMessage(4): %v", err)
+	}
+	if err := s.DeleteUser(aa); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := s.DeleteUser(aa); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("DeleteUser twice: err = %v, want ErrUserNotFound", err)
+	}
+
+	var messages, followers, entries int64
+	s.DB.Model(&models.Messages{}).Count(&messages)
+	s.DB.Model(&models.Followers{}).Count(&followers)
+	s.DB.Model(&models.ModerationLog{}).Count(&entries)
+	if messages != 1 || followers != 0 || entries != 0 {
+		t.Errorf("left %d messages, %d followers, %d log entries, want 1, 0, 0", messages, followers, entries)
+	}
+	if _, err := s.GetUserIDByUsername("aa"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("aa: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestGormStoreCommands(t *testing.T) {
+	s := newSQLiteStore(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cmd := models.Command{CommandID: 7, Method: "POST", Path: "/api/msgs/aa", CreatedAt: now}
+
+	if _, claimed, err := s.ClaimCommand(cmd, now.Add(-time.Minute)); err != nil || !claimed {
+		t.Fatalf("first claim = %v, %v, want claimed", claimed, err)
+	}
+	if stored, claimed, err := s.ClaimCommand(cmd, now.Add(-time.Minute)); err != nil || claimed || stored.Status != 0 {
+		t.Errorf("second claim = %+v, %v, %v, want the running command", stored, claimed, err)
+	}
+	if _, claimed, _ := s.ClaimCommand(cmd, now.Add(time.Second)); !claimed {
+		t.Errorf("stale claim was not taken over")
+	}
+
+	cmd.Status, cmd.ContentType, cmd.Body = 204, "application/json", `""`
+	if err := s.CompleteCommand(cmd, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReleaseCommand(7); err != nil {
+		t.Fatal(err)
+	}
+	stored, claimed, err := s.ClaimCommand(cmd, now.Add(time.Hour))
+	if err != nil || claimed || stored.Status != 204 || stored.Body != `""` {
+		t.Errorf("claim after completion = %+v, %v, %v, want the recorded 204", stored, claimed, err)
+	}
+	if latest, _ := s.GetLatest(); latest != 7 {
+		t.Errorf("latest = %d, want 7", latest)
+	}
+}
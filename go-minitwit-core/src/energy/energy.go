@@ -0,0 +1,136 @@
+// Package energy attributes CPU time and, where the RAPL powercap counters
+// are readable, energy to the routes and handlers of either app. It samples
+// the process around every request, so unlike the external measurements of
+// whole runs it can tell which endpoints the joules go to.
+//
+// The counters are process wide (CPU time) or even package wide (RAPL), so
+// requests that overlap each get the whole delta of their window. The per
+// route numbers are exact for serial runs, under load they are shares to be
+// scaled by the totals.
+package energy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// zone is a top level RAPL domain, one per CPU package
+type zone struct {
+	name string
+	path string
+	// maxRange is where energy_uj wraps around to 0
+	maxRange uint64
+}
+
+// Sampler reads the CPU time of the process and the energy counters
+type Sampler struct {
+	// cpu is ProcessCPU, tests may replace it
+	cpu   func() (time.Duration, error)
+	zones []zone
+}
+
+// Sample is a reading of the counters, only the difference of two means anything
+type Sample struct {
+	CPU time.Duration
+	// Microjoules holds energy_uj of every zone
+	Microjoules []uint64
+}
+
+// NewSampler finds the RAPL zones under powercapDir. Having none (no Intel
+// CPU, a VM, unreadable counters) is not an error, the Sampler then only
+// accounts CPU time. It fails if the CPU time cannot be read.
+func NewSampler(powercapDir string) (*Sampler, error) {
+	s := &Sampler{cpu: ProcessCPU}
+	if _, err := s.cpu(); err != nil {
+		return nil, err
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(powercapDir, "intel-rapl:*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		// intel-rapl:0:0 and the like are parts (core, uncore, dram) of the package
+		if strings.Count(filepath.Base(dir), ":") > 1 {
+			continue
+		}
+		z := zone{name: filepath.Base(dir), path: filepath.Join(dir, "energy_uj")}
+		if name, err := os.ReadFile(filepath.Join(dir, "name")); err == nil {
+			z.name = strings.TrimSpace(string(name))
+		}
+		if _, err := readUint(z.path); err != nil {
+			continue
+		}
+		if z.maxRange, err = readUint(filepath.Join(dir, "max_energy_range_uj")); err != nil {
+			continue
+		}
+		s.zones = append(s.zones, z)
+	}
+	return s, nil
+}
+
+// RAPL tells whether energy is measured, or only CPU time
+func (s *Sampler) RAPL() bool {
+	return len(s.zones) > 0
+}
+
+// Zones are the names of the RAPL zones, in the order of Sample.Microjoules
+func (s *Sampler) Zones() []string {
+	names := make([]string, len(s.zones))
+	for i, z := range s.zones {
+		names[i] = z.name
+	}
+	return names
+}
+
+// Sample reads the counters
+func (s *Sampler) Sample() (Sample, error) {
+	cpu, err := s.cpu()
+	if err != nil {
+		return Sample{}, err
+	}
+	sample := Sample{CPU: cpu, Microjoules: make([]uint64, len(s.zones))}
+	for i, z := range s.zones {
+		if sample.Microjoules[i], err = readUint(z.path); err != nil {
+			return Sample{}, err
+		}
+	}
+	return sample, nil
+}
+
+// Joules is the energy used between start and end, over all zones
+func (s *Sampler) Joules(start, end Sample) float64 {
+	var uj uint64
+	for i, z := range s.zones {
+		if end.Microjoules[i] >= start.Microjoules[i] {
+			uj += end.Microjoules[i] - start.Microjoules[i]
+		} else {
+			uj += z.maxRange - start.Microjoules[i] + end.Microjoules[i]
+		}
+	}
+	return float64(uj) / 1e6
+}
+
+// ProcessCPU is the user and system time of the process, from getrusage(2).
+// Unlike utime and stime in /proc/self/stat, which count 10ms ticks, it has
+// microsecond resolution, fine enough for a single request.
+func ProcessCPU() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, fmt.Errorf("getrusage: %w", err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), nil
+}
+
+func readUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
@@ -0,0 +1,158 @@
+package energy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func write(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeSampler reads the CPU time from *cpu and a powercap dir with one package
+// and one of its subzones
+func fakeSampler(t *testing.T, cpu *time.Duration) (*Sampler, string) {
+	powercap := t.TempDir()
+	write(t, filepath.Join(powercap, "intel-rapl:0", "name"), "package-0\n")
+	write(t, filepath.Join(powercap, "intel-rapl:0", "energy_uj"), "1000000\n")
+	write(t, filepath.Join(powercap, "intel-rapl:0", "max_energy_range_uj"), "5000000\n")
+	write(t, filepath.Join(powercap, "intel-rapl:0:0", "energy_uj"), "1\n")
+	write(t, filepath.Join(powercap, "intel-rapl:0:0", "max_energy_range_uj"), "5000000\n")
+
+	s, err := NewSampler(powercap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.cpu = func() (time.Duration, error) { return *cpu, nil }
+	return s, filepath.Join(powercap, "intel-rapl:0", "energy_uj")
+}
+
+func TestNewSamplerWithoutRAPL(t *testing.T) {
+	s, err := NewSampler(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RAPL() {
+		t.Error("RAPL() = true without zones")
+	}
+	if _, err := s.Sample(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessCPU(t *testing.T) {
+	before, err := ProcessCPU()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// burn a few ms, far below the 10ms ticks of /proc/self/stat
+	for start := time.Now(); time.Since(start) < 3*time.Millisecond; {
+	}
+	after, err := ProcessCPU()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := after - before; d <= 0 || d%(10*time.Millisecond) == 0 {
+		t.Errorf("CPU time of a 3ms loop = %v, want a finer resolution than 10ms", d)
+	}
+}
+
+func TestSample(t *testing.T) {
+	cpu := 2 * time.Second
+	s, _ := fakeSampler(t, &cpu)
+	if got := s.Zones(); len(got) != 1 || got[0] != "package-0" {
+		t.Fatalf("Zones() = %v, want [package-0]", got)
+	}
+	sample, err := s.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.CPU != 2*time.Second || sample.Microjoules[0] != 1000000 {
+		t.Errorf("Sample() = %+v, want 2s and 1000000µJ", sample)
+	}
+
+	s.cpu = func() (time.Duration, error) { return 0, errors.New("no rusage") }
+	if _, err := s.Sample(); err == nil {
+		t.Error("Sample() without the CPU time succeeded")
+	}
+}
+
+func TestJoulesWrapsAround(t *testing.T) {
+	var cpu time.Duration
+	s, _ := fakeSampler(t, &cpu)
+	start := Sample{Microjoules: []uint64{4500000}}
+	end := Sample{Microjoules: []uint64{500000}}
+	if got := s.Joules(start, end); got != 1 {
+		t.Errorf("Joules across the wrap = %v, want 1", got)
+	}
+}
+
+func TestMeter(t *testing.T) {
+	cpu := 2 * time.Second
+	s, energyUJ := fakeSampler(t, &cpu)
+	m := NewMeter(s)
+
+	done := m.Begin()
+	cpu += 1500 * time.Microsecond
+	write(t, energyUJ, "3500000\n")
+	done("GET", "/public", "PublicTimelineHandler")
+	m.Begin()("GET", "/public", "PublicTimelineHandler")
+	m.Begin()("GET", "", "NotFound")
+
+	r := m.Report()
+	if !r.RAPL || len(r.Routes) != 2 {
+		t.Fatalf("Report() = %+v, want RAPL and 2 routes", r)
+	}
+	public := r.Routes[0]
+	if public.Route != "/public" || public.Handler != "PublicTimelineHandler" || public.Requests != 2 {
+		t.Errorf("routes[0] = %+v, want 2 requests of /public", public)
+	}
+	if public.CPUSeconds != 0.0015 || public.Joules != 2.5 {
+		t.Errorf("routes[0] = %v CPU seconds and %v J, want 0.0015 and 2.5", public.CPUSeconds, public.Joules)
+	}
+	if unmatched := r.Routes[1]; unmatched.Route != UnmatchedRoute || unmatched.Handler != "" {
+		t.Errorf("routes[1] = %+v, want the unmatched route", unmatched)
+	}
+	if r.Total.Requests != 3 || r.Total.Joules != 2.5 {
+		t.Errorf("total = %+v, want 3 requests and 2.5 J", r.Total)
+	}
+
+	m.Reset()
+	if after := m.Report(); len(after.Routes) != 0 || after.Total.Requests != 0 || after.Since.Before(r.Since) {
+		t.Errorf("Report() after Reset = %+v", after)
+	}
+}
+
+func TestMeterUnknownMethods(t *testing.T) {
+	var cpu time.Duration
+	s, _ := fakeSampler(t, &cpu)
+	m := NewMeter(s)
+	m.Begin()("FOO123", "", "")
+	m.Begin()("BAR456", "", "")
+
+	if r := m.Report(); len(r.Routes) != 1 || r.Routes[0].Method != "other" || r.Routes[0].Requests != 2 {
+		t.Errorf("Report() = %+v, want both requests under the method other", r.Routes)
+	}
+}
+
+func TestHandlerName(t *testing.T) {
+	for name, want := range map[string]string{
+		"go-gin/src/handlers.(*Handler).PublicTimelineHandler-fm": "PublicTimelineHandler",
+		"go-gorilla/src/handlers.(*Handler).API_Msgs-fm":          "API_Msgs",
+		"main.main.func1": "func1",
+		"ApiMsgsHandler":  "ApiMsgsHandler",
+	} {
+		if got := HandlerName(name); got != want {
+			t.Errorf("HandlerName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package energy
+
+import (
+	"cmp"
+	"go-minitwit-core/src/metrics"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnmatchedRoute is the route of requests no route matched, as in package metrics
+const UnmatchedRoute = "unmatched"
+
+// key is what the totals are kept by
+type key struct {
+	method  string
+	route   string
+	handler string
+}
+
+type totals struct {
+	requests int
+	cpu      time.Duration
+	joules   float64
+	wall     time.Duration
+}
+
+// Meter sums the samples of the requests per route and handler
+type Meter struct {
+	sampler *Sampler
+
+	mu     sync.Mutex
+	since  time.Time
+	routes map[key]*totals
+}
+
+// Route is the accounting of one route and handler
+type Route struct {
+	Method     string  `json:"method"`
+	Route      string  `json:"route"`
+	Handler    string  `json:"handler"`
+	Requests   int     `json:"requests"`
+	CPUSeconds float64 `json:"cpu_seconds"`
+	// Joules is 0 without RAPL
+	Joules      float64 `json:"joules"`
+	WallSeconds float64 `json:"wall_seconds"`
+}
+
+// Total is the sum over the routes
+type Total struct {
+	Requests    int     `json:"requests"`
+	CPUSeconds  float64 `json:"cpu_seconds"`
+	Joules      float64 `json:"joules"`
+	WallSeconds float64 `json:"wall_seconds"`
+}
+
+// Report is the JSON served at /metrics/energy
+type Report struct {
+	Since  time.Time `json:"since"`
+	RAPL   bool      `json:"rapl"`
+	Zones  []string  `json:"zones"`
+	Total  Total     `json:"total"`
+	Routes []Route   `json:"routes"`
+}
+
+// NewMeter starts accounting with the counters of s
+func NewMeter(s *Sampler) *Meter {
+	return &Meter{sampler: s, since: time.Now(), routes: map[key]*totals{}}
+}
+
+// Begin samples the counters at the start of a request. The returned func
+// samples them again once it is answered and adds the difference to route and
+// handler, which are only known after routing. A route of "" is UnmatchedRoute,
+// and a method no route uses is metrics.OtherMethod, so that clients cannot
+// add totals at will.
+func (m *Meter) Begin() func(method string, route string, handler string) {
+	start := time.Now()
+	before, err := m.sampler.Sample()
+	if err != nil {
+		log.Printf("energy: %v", err)
+		return func(string, string, string) {}
+	}
+	return func(method string, route string, handler string) {
+		after, err := m.sampler.Sample()
+		if err != nil {
+			log.Printf("energy: %v", err)
+			return
+		}
+		if route == "" {
+			route, handler = UnmatchedRoute, ""
+		}
+		k := key{method: metrics.Method(method), route: route, handler: handler}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		t, ok := m.routes[k]
+		if !ok {
+			t = &totals{}
+			m.routes[k] = t
+		}
+		t.requests++
+		t.cpu += after.CPU - before.CPU
+		t.joules += m.sampler.Joules(before, after)
+		t.wall += time.Since(start)
+	}
+}
+
+// Report is the accounting since the start or the last Reset, the routes
+// sorted by route, method and handler
+func (m *Meter) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := Report{Since: m.since, RAPL: m.sampler.RAPL(), Zones: m.sampler.Zones(), Routes: []Route{}}
+	for k, t := range m.routes {
+		route := Route{
+			Method:      k.method,
+			Route:       k.route,
+			Handler:     k.handler,
+			Requests:    t.requests,
+			CPUSeconds:  t.cpu.Seconds(),
+			Joules:      t.joules,
+			WallSeconds: t.wall.Seconds(),
+		}
+		r.Routes = append(r.Routes, route)
+		r.Total.Requests += route.Requests
+		r.Total.CPUSeconds += route.CPUSeconds
+		r.Total.Joules += route.Joules
+		r.Total.WallSeconds += route.WallSeconds
+	}
+	slices.SortFunc(r.Routes, func(a, b Route) int {
+		return cmp.Or(cmp.Compare(a.Route, b.Route), cmp.Compare(a.Method, b.Method), cmp.Compare(a.Handler, b.Handler))
+	})
+	return r
+}
+
+// Reset drops the totals, e.g. between two measured runs
+func (m *Meter) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.since = time.Now()
+	m.routes = map[key]*totals{}
+}
+
+// HandlerName shortens a Go function name such as
+// minitwit/src/handlers.(*Handler).PublicTimelineHandler-fm to PublicTimelineHandler
+func HandlerName(name string) string {
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
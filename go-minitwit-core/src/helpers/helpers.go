@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"go-minitwit-core/src/models"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Helper functions
+func GravatarURL(email string, size int) string {
+	if size <= 0 {
+		size = 80 // Default size
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	hash := md5.Sum([]byte(email))
+	return fmt.Sprintf("http://www.gravatar.com/avatar/%x?d=identicon&s=%d", hash, size)
+}
+
+// DateFormat selects the extra pub_date form of the API messages, pub_date
+// itself is always unix seconds so its type never changes under a client
+type DateFormat string
+
+const (
+	DateUnix    DateFormat = "unix"
+	DateRFC3339 DateFormat = "rfc3339"
+)
+
+var ErrInvalidDateFormat = errors.New("invalid date_format")
+
+// ParseDateFormat reads the ?date_format= query value, empty means unix
+func ParseDateFormat(s string) (DateFormat, error) {
+	switch DateFormat(strings.ToLower(s)) {
+	case "", DateUnix:
+		return DateUnix, nil
+	case DateRFC3339:
+		return DateRFC3339, nil
+	}
+	return "", fmt.Errorf("%w %q, want unix or rfc3339", ErrInvalidDateFormat, s)
+}
+
+func FilterMessages(messages []models.MessageUser, format DateFormat) []models.FilteredMsg {
+	var filteredMessages []models.FilteredMsg
+	for _, m := range messages {
+		var filteredMsg models.FilteredMsg
+		filteredMsg.MessageID = m.MessageID
+
+		// content
+		if reflect.TypeOf(m.Text).Kind() == reflect.String {
+			filteredMsg.Content = m.Text
+		}
+
+		// publication date
+		filteredMsg.PubDate = m.PubDate.Unix()
+		if format == DateRFC3339 {
+			filteredMsg.PubDateRFC3339 = m.PubDate.UTC().Format(time.RFC3339)
+		}
+
+		// user
+		if reflect.TypeOf(m.Username).Kind() == reflect.String {
+			filteredMsg.User = m.Username
+		}
+
+		filteredMessages = append(filteredMessages, filteredMsg)
+	}
+	return filteredMessages
+}
+
+// FilterFlaggedMessages is FilterMessages for the moderation API
+func FilterFlaggedMessages(messages []models.FlaggedMessage, format DateFormat) []models.FilteredFlaggedMsg {
+	filteredMessages := []models.FilteredFlaggedMsg{}
+	for _, m := range messages {
+		filteredMsg := models.FilteredFlaggedMsg{
+			FilteredMsg: FilterMessages([]models.MessageUser{m.MessageUser}, format)[0],
+			FlaggedBy:   m.FlaggedBy,
+		}
+		if !m.FlaggedAt.IsZero() {
+			filteredMsg.FlaggedAt = m.FlaggedAt.Unix()
+		}
+		filteredMessages = append(filteredMessages, filteredMsg)
+	}
+	return filteredMessages
+}
+
+func FormatMessages(messages []models.MessageUser) []models.MessageUI {
+	var formattedMessages []models.MessageUI
+
+	for _, m := range messages {
+		// Initialize a new MessageUI struct
+		var msg models.MessageUI
+
+		// Directly assign fields from MessageUser to MessageUI
+		msg.MessageID = m.MessageID
+		msg.AuthorID = m.AuthorID
+		msg.User.UserID = m.UserID
+		msg.Text = m.Text
+		msg.Flagged = m.Flagged != 0
+		msg.Username = m.Username
+		msg.Email = m.Email
+		msg.PubDate = Format_datetime(m.PubDate) // Assuming PubDate is already a time.Time type
+
+		// Create the profile link by encoding the username
+		msg.Profile_link = "/user/" + strings.ReplaceAll(msg.Username, " ", "%20")
+
+		// Generate the Gravatar URL
+		msg.Gravatar = GravatarURL(msg.Email, 48)
+
+		// Append the formatted message to the result slice
+		formattedMessages = append(formattedMessages, msg)
+	}
+
+	return formattedMessages
+}
+
+// FormatFlaggedMessages is FormatMessages for the flagged messages page
+func FormatFlaggedMessages(messages []models.FlaggedMessage) []models.MessageUI {
+	formattedMessages := make([]models.MessageUI, 0, len(messages))
+	for _, m := range messages {
+		msg := FormatMessages([]models.MessageUser{m.MessageUser})[0]
+		msg.FlaggedBy = m.FlaggedBy
+		if !m.FlaggedAt.IsZero() {
+			msg.FlaggedAt = Format_datetime(m.FlaggedAt)
+		}
+		formattedMessages = append(formattedMessages, msg)
+	}
+	return formattedMessages
+}
+
+func Format_datetime(timestamp time.Time) string {
+
+	// Format the time.Time object into your desired display format
+	return timestamp.Format("2006-01-02 @ 15:04") // Customize this layout as needed
+}
+
+// LinkHeader renders an RFC 8288 Link header for a timeline page: rel="next"
+// points to the older page and rel="prev" to the newer one. Other query
+// parameters of u are kept.
+func LinkHeader(u *url.URL, older string, newer string) string {
+	link := func(param string, value string, rel string) string {
+		q := u.Query()
+		q.Del("before")
+		q.Del("after")
+		q.Set(param, value)
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, q.Encode(), rel)
+	}
+
+	var links []string
+	if older != "" {
+		links = append(links, link("before", older, "next"))
+	}
+	if newer != "" {
+		links = append(links, link("after", newer, "prev"))
+	}
+	return strings.Join(links, ", ")
+}
+
+func IsNil(i interface{}) bool {
+	return i == nil || i == interface{}(nil)
+}
@@ -0,0 +1,466 @@
+// Package memstore is an in-memory store.Store with the same semantics as
+// db.GormStore. It lets handlers be exercised with httptest and no database.
+package memstore
+
+import (
+	"cmp"
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/store"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu            sync.RWMutex
+	users         []models.Users
+	messages      []models.Messages
+	followers     []models.Followers
+	moderationLog []models.ModerationLog
+	commands      map[int]models.Command
+	latest        int
+
+	nextUserID    int
+	nextMessageID int
+	nextLogID     int
+
+	// Now is used to stamp new messages, tests may replace it
+	Now func() time.Time
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		commands:      map[int]models.Command{},
+		latest:        -1, // matches the row inserted by database/schema.sql
+		nextUserID:    1,
+		nextMessageID: 1,
+		nextLogID:     1,
+		Now:           time.Now,
+	}
+}
+
+func (s *Store) GetUserNameByUserID(userID int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.userByID(userID)
+	if !ok {
+		return "", store.ErrUserNotFound
+	}
+	return user.Username, nil
+}
+
+func (s *Store) GetUserIDByUsername(userName string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.userByName(userName)
+	if !ok {
+		return -1, store.ErrUserNotFound
+	}
+	return user.UserID, nil
+}
+
+func (s *Store) GetUserByUsername(userName string) (models.Users, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.userByName(userName)
+	if !ok {
+		return user, store.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *Store) RegisterUser(userName string, email string, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByName(userName); ok {
+		return store.ErrDuplicateUsername
+	}
+	s.users = append(s.users, models.Users{
+		UserID:   s.nextUserID,
+		Username: userName,
+		Email:    email,
+		Pwd:      password,
+	})
+	s.nextUserID++
+	return nil
+}
+
+func (s *Store) UpdatePassword(userID int, pwHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.users {
+		if s.users[i].UserID == userID {
+			s.users[i].Pwd = pwHash
+			return nil
+		}
+	}
+	return store.ErrUserNotFound
+}
+
+func (s *Store) DeleteUser(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByID(userID); !ok {
+		return store.ErrUserNotFound
+	}
+	authored := map[int]bool{}
+	for _, m := range s.messages {
+		if m.AuthorID == userID {
+			authored[m.MessageID] = true
+		}
+	}
+	s.moderationLog = slices.DeleteFunc(s.moderationLog, func(entry models.ModerationLog) bool {
+		return authored[entry.MessageID] || entry.ModeratorID == userID
+	})
+	s.followers = slices.DeleteFunc(s.followers, func(f models.Followers) bool {
+		return f.WhoID == userID || f.WhomID == userID
+	})
+	s.messages = slices.DeleteFunc(s.messages, func(m models.Messages) bool {
+		return m.AuthorID == userID
+	})
+	s.users = slices.DeleteFunc(s.users, func(u models.Users) bool {
+		return u.UserID == userID
+	})
+	return nil
+}
+
+func (s *Store) GetPublicMessages(page store.Page) ([]models.MessageUser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := s.join(func(m models.Messages) bool {
+		return m.Flagged == 0
+	})
+	return paginate(messages, page, true), nil
+}
+
+func (s *Store) GetMyMessages(userID int, page store.Page) ([]models.MessageUser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	followed := map[int]bool{userID: true}
+	for _, f := range s.followers {
+		if f.WhoID == userID {
+			followed[f.WhomID] = true
+		}
+	}
+
+	messages := s.join(func(m models.Messages) bool {
+		return m.Flagged == 0 && followed[m.AuthorID]
+	})
+	return paginate(messages, page, true), nil
+}
+
+func (s *Store) GetUserMessages(pUserId int, page store.Page) ([]models.MessageUser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := s.join(func(m models.Messages) bool {
+		return m.Flagged == 0 && m.AuthorID == pUserId
+	})
+	return paginate(messages, page, false), nil
+}
+
+func (s *Store) AddMessage(text string, authorID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByID(authorID); !ok {
+		return store.ErrUserNotFound
+	}
+	s.messages = append(s.messages, models.Messages{
+		MessageID: s.nextMessageID,
+		AuthorID:  authorID,
+		Content:   text,
+		PubDate:   s.Now().UTC().Truncate(time.Second), // same precision as the RFC3339 round trip in db.AddMessage
+		Flagged:   0,
+	})
+	s.nextMessageID++
+	return nil
+}
+
+func (s *Store) DeleteMessage(messageID int, authorID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.messages, func(m models.Messages) bool {
+		return m.MessageID == messageID && m.AuthorID == authorID
+	})
+	if i == -1 {
+		return store.ErrMessageNotFound
+	}
+	s.messages = slices.Delete(s.messages, i, i+1)
+	s.moderationLog = slices.DeleteFunc(s.moderationLog, func(entry models.ModerationLog) bool {
+		return entry.MessageID == messageID
+	})
+	return nil
+}
+
+func (s *Store) GetFollowing(userID int, numUsers int) ([]models.Users, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []models.Users{}
+	for _, f := range s.followers {
+		if f.WhoID != userID {
+			continue
+		}
+		if user, ok := s.userByID(f.WhomID); ok {
+			users = append(users, user)
+		}
+	}
+	return limit(users, numUsers), nil
+}
+
+func (s *Store) FollowUser(userID int, profileUserID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByID(userID); !ok {
+		return store.ErrUserNotFound
+	}
+	if _, ok := s.userByID(profileUserID); !ok {
+		return store.ErrUserNotFound
+	}
+	for _, f := range s.followers {
+		if f.WhoID == userID && f.WhomID == profileUserID {
+			return nil
+		}
+	}
+	s.followers = append(s.followers, models.Followers{WhoID: userID, WhomID: profileUserID})
+	return nil
+}
+
+func (s *Store) UnfollowUser(userID int, profileUserID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.followers[:0]
+	for _, f := range s.followers {
+		if f.WhoID == userID && f.WhomID == profileUserID {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	s.followers = kept
+	return nil
+}
+
+func (s *Store) SetFlagged(messageID int, flagged bool, moderatorID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.messages, func(m models.Messages) bool { return m.MessageID == messageID })
+	if i == -1 {
+		return store.ErrMessageNotFound
+	}
+	if _, ok := s.userByID(moderatorID); !ok {
+		return store.ErrUserNotFound
+	}
+	value := 0
+	if flagged {
+		value = 1
+	}
+	s.messages[i].Flagged = value
+	s.moderationLog = append(s.moderationLog, models.ModerationLog{
+		ID:          s.nextLogID,
+		MessageID:   messageID,
+		ModeratorID: moderatorID,
+		Flagged:     value,
+		CreatedAt:   s.Now().UTC(),
+	})
+	s.nextLogID++
+	return nil
+}
+
+func (s *Store) GetFlaggedMessages(numMessages int) ([]models.FlaggedMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// the newest flag entry of every message, IDs grow with time
+	last := map[int]models.ModerationLog{}
+	for _, entry := range s.moderationLog {
+		if entry.Flagged != 0 {
+			last[entry.MessageID] = entry
+		}
+	}
+
+	flagged := []models.FlaggedMessage{}
+	for _, m := range s.join(func(m models.Messages) bool { return m.Flagged != 0 }) {
+		fm := models.FlaggedMessage{MessageUser: m}
+		if entry, ok := last[m.MessageID]; ok {
+			moderator, _ := s.userByID(entry.ModeratorID)
+			fm.FlaggedBy = moderator.Username
+			fm.FlaggedAt = entry.CreatedAt
+		}
+		flagged = append(flagged, fm)
+	}
+	sort.SliceStable(flagged, func(i, j int) bool {
+		return last[flagged[i].MessageID].ID > last[flagged[j].MessageID].ID
+	})
+	return limit(flagged, numMessages), nil
+}
+
+func (s *Store) GetLatest() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.latest, nil
+}
+
+func (s *Store) UpdateLatest(commandID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = commandID
+	return nil
+}
+
+func (s *Store) ClaimCommand(cmd models.Command, staleBefore time.Time) (models.Command, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.commands[cmd.CommandID]
+	if ok && (stored.Status != 0 || !stored.CreatedAt.Before(staleBefore)) {
+		return stored, false, nil
+	}
+	cmd.Status = 0
+	s.commands[cmd.CommandID] = cmd
+	return cmd, true, nil
+}
+
+func (s *Store) CompleteCommand(cmd models.Command, latest bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands[cmd.CommandID] = cmd
+	if latest {
+		s.latest = cmd.CommandID
+	}
+	return nil
+}
+
+func (s *Store) ReleaseCommand(commandID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.commands[commandID].Status == 0 {
+		delete(s.commands, commandID)
+	}
+	return nil
+}
+
+func (s *Store) userByID(userID int) (models.Users, bool) {
+	for _, u := range s.users {
+		if u.UserID == userID {
+			return u, true
+		}
+	}
+	return models.Users{}, false
+}
+
+func (s *Store) userByName(userName string) (models.Users, bool) {
+	for _, u := range s.users {
+		if u.Username == userName {
+			return u, true
+		}
+	}
+	return models.Users{}, false
+}
+
+// join mirrors `messages JOIN users ON messages.author_id = users.user_id`
+func (s *Store) join(keep func(models.Messages) bool) []models.MessageUser {
+	messages := []models.MessageUser{}
+	for _, m := range s.messages {
+		if !keep(m) {
+			continue
+		}
+		author, ok := s.userByID(m.AuthorID)
+		if !ok {
+			continue
+		}
+		messages = append(messages, models.MessageUser{
+			MessageID: m.MessageID,
+			AuthorID:  m.AuthorID,
+			Text:      m.Content,
+			PubDate:   m.PubDate,
+			Flagged:   m.Flagged,
+			UserID:    author.UserID,
+			Username:  author.Username,
+			Email:     author.Email,
+		})
+	}
+	return messages
+}
+
+// sortByPubDate orders by pub_date, ties are broken by message_id so results are stable
+func sortByPubDate(messages []models.MessageUser, desc bool) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		if !a.PubDate.Equal(b.PubDate) {
+			if desc {
+				return a.PubDate.After(b.PubDate)
+			}
+			return a.PubDate.Before(b.PubDate)
+		}
+		if desc {
+			return a.MessageID > b.MessageID
+		}
+		return a.MessageID < b.MessageID
+	})
+}
+
+// paginate mirrors the keyset query of db.GormStore: messages next to a cursor
+// are read walking away from it and then put back into display order
+func paginate(messages []models.MessageUser, page store.Page, newestFirst bool) []models.MessageUser {
+	var cursor *store.Cursor
+	walkDesc, reverse := newestFirst, false
+	switch {
+	case page.Before != nil:
+		cursor, walkDesc, reverse = page.Before, true, !newestFirst
+	case page.After != nil:
+		cursor, walkDesc, reverse = page.After, false, newestFirst
+	}
+
+	sortByPubDate(messages, walkDesc)
+	if cursor != nil {
+		kept := messages[:0]
+		for _, m := range messages {
+			if c := compareCursor(m, *cursor); (walkDesc && c < 0) || (!walkDesc && c > 0) {
+				kept = append(kept, m)
+			}
+		}
+		messages = kept
+	}
+
+	messages = limit(messages, page.Limit)
+	if reverse {
+		slices.Reverse(messages)
+	}
+	return messages
+}
+
+// compareCursor compares (pub_date, message_id) of m with c
+func compareCursor(m models.MessageUser, c store.Cursor) int {
+	if d := m.PubDate.Compare(c.PubDate); d != 0 {
+		return d
+	}
+	return cmp.Compare(m.MessageID, c.MessageID)
+}
+
+// limit behaves like SQL LIMIT, a negative n means no limit
+func limit[T any](items []T, n int) []T {
+	if n >= 0 && len(items) > n {
+		return items[:n]
+	}
+	return items
+}
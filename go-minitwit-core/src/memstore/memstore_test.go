@@ -0,0 +1,245 @@
+package memstore
+
+import (
+	"errors"
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s := New()
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+
+	for _, name := range []string{"aa", "bb", "cc"} {
+		if err := s.RegisterUser(name, name+"@example.com", "pwd"); err != nil {
+			t.Fatalf("RegisterUser(%q): %v", name, err)
+		}
+	}
+	return s
+}
+
+func mustUserID(t *testing.T, s *Store, name string) int {
+	t.Helper()
+
+	id, err := s.GetUserIDByUsername(name)
+	if err != nil || id == -1 {
+		t.Fatalf("GetUserIDByUsername(%q) = %d, %v", name, id, err)
+	}
+	return id
+}
+
+func TestUnknownAndDuplicateUser(t *testing.T) {
+	s := newTestStore(t)
+	aa := mustUserID(t, s, "aa")
+
+	if id, err := s.GetUserIDByUsername("nobody"); id != -1 || !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUserIDByUsername(nobody) = %d, %v, want -1, ErrUserNotFound", id, err)
+	}
+	if _, err := s.GetUserByUsername("nobody"); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUserByUsername(nobody) error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := s.GetUserNameByUserID(42); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUserNameByUserID(42) error = %v, want ErrUserNotFound", err)
+	}
+	if err := s.FollowUser(aa, 42); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("FollowUser(aa, 42) error = %v, want ErrUserNotFound", err)
+	}
+	if err := s.AddMessage("orphan", 42); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("AddMessage by 42 error = %v, want ErrUserNotFound", err)
+	}
+	if err := s.RegisterUser("aa", "other@example.com", "pwd"); !errors.Is(err, store.ErrDuplicateUsername) {
+		t.Errorf("RegisterUser(aa) again error = %v, want ErrDuplicateUsername", err)
+	}
+}
+
+func TestPublicMessagesOrderingFlaggingAndLimit(t *testing.T) {
+	s := newTestStore(t)
+	aa, bb := mustUserID(t, s, "aa"), mustUserID(t, s, "bb")
+
+	for _, m := range []struct {
+		text   string
+		author int
+	}{{"first", aa}, {"second", bb}, {"third", aa}} {
+		if err := s.AddMessage(m.text, m.author); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.messages[1].Flagged = 1
+
+	messages, err := s.GetPublicMessages(store.FirstPage(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Text != "third" || messages[1].Text != "first" {
+		t.Fatalf("GetPublicMessages = %+v, want [third first]", messages)
+	}
+	if messages[0].Username != "aa" || messages[0].Email != "aa@example.com" {
+		t.Errorf("message author not joined: %+v", messages[0])
+	}
+
+	limited, _ := s.GetPublicMessages(store.FirstPage(1))
+	if len(limited) != 1 || limited[0].Text != "third" {
+		t.Errorf("GetPublicMessages(1) = %+v, want [third]", limited)
+	}
+}
+
+func TestUserMessagesAreOldestFirst(t *testing.T) {
+	s := newTestStore(t)
+	aa := mustUserID(t, s, "aa")
+
+	_ = s.AddMessage("one", aa)
+	_ = s.AddMessage("two", aa)
+
+	messages, _ := s.GetUserMessages(aa, store.FirstPage(30))
+	if len(messages) != 2 || messages[0].Text != "one" || messages[1].Text != "two" {
+		t.Errorf("GetUserMessages = %+v, want [one two]", messages)
+	}
+}
+
+func texts(messages []models.MessageUser) string {
+	var t []string
+	for _, m := range messages {
+		t = append(t, m.Text)
+	}
+	return strings.Join(t, " ")
+}
+
+func TestKeysetPagination(t *testing.T) {
+	s := newTestStore(t)
+	aa := mustUserID(t, s, "aa")
+
+	fixed := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return fixed } // same pub_date, message_id breaks the tie
+	for _, text := range []string{"m1", "m2", "m3", "m4", "m5"} {
+		_ = s.AddMessage(text, aa)
+	}
+	at := func(id int) *store.Cursor { return &store.Cursor{PubDate: fixed, MessageID: id} }
+
+	tests := []struct {
+		name string
+		get  func() ([]models.MessageUser, error)
+		want string
+	}{
+		{"public first page", func() ([]models.MessageUser, error) { return s.GetPublicMessages(store.FirstPage(2)) }, "m5 m4"},
+		{"public older", func() ([]models.MessageUser, error) { return s.GetPublicMessages(store.Page{Limit: 2, Before: at(4)}) }, "m3 m2"},
+		{"public newer", func() ([]models.MessageUser, error) { return s.GetPublicMessages(store.Page{Limit: 2, After: at(1)}) }, "m3 m2"},
+		{"user first page", func() ([]models.MessageUser, error) { return s.GetUserMessages(aa, store.FirstPage(2)) }, "m1 m2"},
+		{"user newer", func() ([]models.MessageUser, error) { return s.GetUserMessages(aa, store.Page{Limit: 2, After: at(2)}) }, "m3 m4"},
+		{"user older", func() ([]models.MessageUser, error) {
+			return s.GetUserMessages(aa, store.Page{Limit: 2, Before: at(5)})
+		}, "m3 m4"},
+		{"my older", func() ([]models.MessageUser, error) {
+			return s.GetMyMessages(aa, store.Page{Limit: 10, Before: at(3)})
+		}, "m2 m1"},
+	}
+	for _, tt := range tests {
+		messages, err := tt.get()
+		if err != nil || texts(messages) != tt.want {
+			t.Errorf("%s = %q, %v, want %q", tt.name, texts(messages), err, tt.want)
+		}
+	}
+}
+
+func TestFollowAndMyTimeline(t *testing.T) {
+	s := newTestStore(t)
+	aa, bb, cc := mustUserID(t, s, "aa"), mustUserID(t, s, "bb"), mustUserID(t, s, "cc")
+
+	_ = s.AddMessage("from aa", aa)
+	_ = s.AddMessage("from bb", bb)
+	_ = s.AddMessage("from cc", cc)
+
+	_ = s.FollowUser(aa, bb)
+	_ = s.FollowUser(aa, bb) // following twice is a no-op
+
+	following, _ := s.GetFollowing(aa, 100)
+	if len(following) != 1 || following[0].Username != "bb" {
+		t.Fatalf("GetFollowing = %+v, want [bb]", following)
+	}
+
+	messages, _ := s.GetMyMessages(aa, store.FirstPage(-1))
+	if len(messages) != 2 || messages[0].Text != "from bb" || messages[1].Text != "from aa" {
+		t.Errorf("GetMyMessages = %+v, want [from bb, from aa]", messages)
+	}
+
+	_ = s.UnfollowUser(aa, bb)
+	if following, _ := s.GetFollowing(aa, 100); len(following) != 0 {
+		t.Errorf("GetFollowing after unfollow = %+v, want none", following)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	s := New()
+
+	if latest, _ := s.GetLatest(); latest != -1 {
+		t.Errorf("initial latest = %d, want -1", latest)
+	}
+	_ = s.UpdateLatest(1337)
+	if latest, _ := s.GetLatest(); latest != 1337 {
+		t.Errorf("latest = %d, want 1337", latest)
+	}
+}
+
+func TestModeration(t *testing.T) {
+	s := newTestStore(t)
+	aa, bb := mustUserID(t, s, "aa"), mustUserID(t, s, "bb")
+	for _, text := range []string{"a1", "a2", "a3"} {
+		_ = s.AddMessage(text, aa)
+	}
+
+	_ = s.SetFlagged(2, true, bb)
+	_ = s.SetFlagged(1, true, bb)
+	_ = s.SetFlagged(1, false, bb)
+	s.messages[2].Flagged = 1 // flagged outside the moderation log
+	if err := s.SetFlagged(99, true, bb); !errors.Is(err, store.ErrMessageNotFound) {
+		t.Errorf("SetFlagged(99) error = %v, want ErrMessageNotFound", err)
+	}
+
+	flagged, _ := s.GetFlaggedMessages(10)
+	if len(flagged) != 2 || flagged[0].Text != "a2" || flagged[0].FlaggedBy != "bb" || flagged[1].Text != "a3" || flagged[1].FlaggedBy != "" {
+		t.Errorf("GetFlaggedMessages = %+v, want a2 flagged by bb, then a3", flagged)
+	}
+	if messages, _ := s.GetUserMessages(aa, store.FirstPage(10)); texts(messages) != "a1" {
+		t.Errorf("GetUserMessages = %q, want the flagged messages hidden", texts(messages))
+	}
+	if len(s.moderationLog) != 3 {
+		t.Errorf("%d moderation log entries, want 3", len(s.moderationLog))
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestStore(t)
+	aa, bb := mustUserID(t, s, "aa"), mustUserID(t, s, "bb")
+	_ = s.AddMessage("a1", aa)
+	_ = s.AddMessage("b1", bb)
+	_ = s.AddMessage("b2", bb)
+	_ = s.FollowUser(aa, bb)
+	_ = s.FollowUser(bb, aa)
+	_ = s.SetFlagged(1, true, bb)
+	_ = s.SetFlagged(2, true, aa)
+
+	if err := s.DeleteMessage(3, aa); !errors.Is(err, store.ErrMessageNotFound) {
+		t.Errorf("DeleteMessage of another author: err = %v, want ErrMessageNotFound", err)
+	}
+	if err := s.DeleteMessage(3, bb); err != nil {
+		t.Fatalf("DeleteMessage(3): %v", err)
+	}
+	if err := s.DeleteUser(aa); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := s.DeleteUser(aa); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("DeleteUser twice: err = %v, want ErrUserNotFound", err)
+	}
+
+	if len(s.messages) != 1 || len(s.followers) != 0 || len(s.moderationLog) != 0 {
+		t.Errorf("left %d messages, %d followers, %d log entries, want 1, 0, 0", len(s.messages), len(s.followers), len(s.moderationLog))
+	}
+}
@@ -0,0 +1,122 @@
+// Package metrics collects the Prometheus metrics both apps serve at /metrics:
+// requests and latencies per route, the duration of every store call, the
+// session store operations and the Go runtime and process stats (GC pauses,
+// goroutines, heap, CPU seconds), so resource use can be lined up with
+// external energy measurements.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// UnmatchedRoute is the route label of requests no route matched, so that
+// scans of random paths cannot grow the number of series
+const UnmatchedRoute = "unmatched"
+
+// OtherMethod is the method label of requests with a method outside
+// knownMethods, which net/http accepts as any token a client makes up
+const OtherMethod = "other"
+
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Method is the label of method, OtherMethod for the ones no route uses
+func Method(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return OtherMethod
+}
+
+// Registry holds the Minitwit metrics next to the runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "minitwit_http_requests_total",
+		Help: "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "minitwit_http_request_duration_seconds",
+		Help:    "Time to answer HTTP requests by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "minitwit_db_query_duration_seconds",
+		Help: "Duration of the store calls by function.",
+		// most queries take well under the 5ms DefBuckets starts at
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2.5, 12),
+	}, []string{"function"})
+	dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "minitwit_db_query_errors_total",
+		Help: "Store calls that returned an error, by function.",
+	}, []string{"function"})
+
+	sessionOps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "minitwit_session_operations_total",
+		Help: "Session store operations by operation and result.",
+	}, []string{"operation", "result"})
+	sessionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "minitwit_session_operation_duration_seconds",
+		Help:    "Duration of the session store operations.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2.5, 12),
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		dbDuration, dbErrors,
+		sessionOps, sessionDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// TemplateRoute rewrites a gin route into the mux template style both apps
+// label with: /user/:username becomes /user/{username}, and a catch-all such
+// as /static/*filepath the prefix /static/, like a mux PathPrefix
+func TemplateRoute(route string) string {
+	segments := strings.Split(route, "/")
+	for i, s := range segments {
+		switch {
+		case strings.HasPrefix(s, ":"):
+			segments[i] = "{" + s[1:] + "}"
+		case strings.HasPrefix(s, "*"):
+			segments[i] = ""
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// ObserveRequest records an answered HTTP request. route is the template the
+// router matched (e.g. /api/msgs/{username}), empty for UnmatchedRoute.
+// Like the route, the method label is bounded, see Method.
+func ObserveRequest(method string, route string, status int, d time.Duration) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	method = Method(method)
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+// observe records the duration of an operation that started at start
+func observe(duration *prometheus.HistogramVec, label string, start time.Time) {
+	duration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+}
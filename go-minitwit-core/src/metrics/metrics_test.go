@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"go-minitwit-core/src/memstore"
+	"go-minitwit-core/src/sessionstore"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentStore(t *testing.T) {
+	s := InstrumentStore(memstore.New())
+	_ = s.RegisterUser("aa", "a@a.a", "a")
+
+	before := testutil.ToFloat64(dbErrors.WithLabelValues("GetUserIDByUsername"))
+	if _, err := s.GetUserIDByUsername("aa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUserIDByUsername("nobody"); err == nil {
+		t.Fatal("GetUserIDByUsername(nobody) succeeded")
+	}
+
+	if got := testutil.ToFloat64(dbErrors.WithLabelValues("GetUserIDByUsername")) - before; got != 1 {
+		t.Errorf("errors of GetUserIDByUsername = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(dbDuration, "minitwit_db_query_duration_seconds"); n < 2 {
+		t.Errorf("db duration series = %d, want RegisterUser and GetUserIDByUsername", n)
+	}
+}
+
+func TestInstrumentSessions(t *testing.T) {
+	store, err := sessionstore.New(sessionstore.Config{Backend: sessionstore.BackendMemory, Keys: []string{"test"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := InstrumentSessions(store)
+
+	before := testutil.ToFloat64(sessionOps.WithLabelValues("save", "ok"))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, err := s.Get(r, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["userID"] = 1
+	if err := s.Save(r, httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(sessionOps.WithLabelValues("save", "ok")) - before; got != 1 {
+		t.Errorf("saves = %v, want 1", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ObserveRequest(http.MethodGet, "/api/msgs/{username}", http.StatusOK, 3*time.Millisecond)
+	ObserveRequest(http.MethodGet, "", http.StatusNotFound, time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`minitwit_http_requests_total{method="GET",route="/api/msgs/{username}",status="200"}`,
+		`minitwit_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`minitwit_http_request_duration_seconds_bucket{method="GET",route="/api/msgs/{username}",le="0.005"}`,
+		"go_goroutines",
+		"go_gc_duration_seconds",
+		"go_memstats_heap_alloc_bytes",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("/metrics does not contain %s", want)
+		}
+	}
+}
+
+func TestUnknownMethods(t *testing.T) {
+	ObserveRequest("FOO123", "", http.StatusMethodNotAllowed, time.Millisecond)
+	ObserveRequest("BAR456", "", http.StatusMethodNotAllowed, time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	if strings.Contains(body, "FOO123") || strings.Contains(body, "BAR456") {
+		t.Error("/metrics has a series per made-up method")
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues(OtherMethod, UnmatchedRoute, "405")); got != 2 {
+		t.Errorf(`requests{method="other"} = %v, want both in one series`, got)
+	}
+}
+
+func TestTemplateRoute(t *testing.T) {
+	for route, want := range map[string]string{
+		"/user/:username":     "/user/{username}",
+		"/api/msgs/:username": "/api/msgs/{username}",
+		"/:username/follow":   "/{username}/follow",
+		"/static/*filepath":   "/static/",
+		"/public":             "/public",
+		"/flag/:message_id":   "/flag/{message_id}",
+		"":                    "",
+	} {
+		if got := TemplateRoute(route); got != want {
+			t.Errorf("TemplateRoute(%q) = %q, want %q", route, got, want)
+		}
+	}
+}
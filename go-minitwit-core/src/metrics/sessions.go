@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"go-minitwit-core/src/sessionstore"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// Sessions counts and times the operations of the wrapped session store
+type Sessions struct {
+	next sessionstore.Store
+}
+
+var _ sessionstore.Store = (*Sessions)(nil)
+
+// InstrumentSessions wraps s so its operations show up in minitwit_session_operations_total
+func InstrumentSessions(s sessionstore.Store) *Sessions {
+	return &Sessions{next: s}
+}
+
+// sessionDone is deferred with the start of the operation, err is read when it returns
+func sessionDone(operation string, start time.Time, err *error) {
+	observe(sessionDuration, operation, start)
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	sessionOps.WithLabelValues(operation, result).Inc()
+}
+
+func (s *Sessions) Get(r *http.Request, name string) (_ *sessions.Session, err error) {
+	defer sessionDone("get", time.Now(), &err)
+	return s.next.Get(r, name)
+}
+
+func (s *Sessions) New(r *http.Request, name string) (_ *sessions.Session, err error) {
+	defer sessionDone("new", time.Now(), &err)
+	return s.next.New(r, name)
+}
+
+func (s *Sessions) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) (err error) {
+	defer sessionDone("save", time.Now(), &err)
+	return s.next.Save(r, w, session)
+}
+
+func (s *Sessions) SetOptions(opts *sessions.Options) {
+	s.next.SetOptions(opts)
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/store"
+	"time"
+)
+
+// Store times every call of the wrapped store.Store, labelled with the
+// method name, so the query durations can be told apart per function
+type Store struct {
+	next store.Store
+}
+
+// Store wraps every method, not embedding store.Store keeps a new one from
+// going untimed
+var _ store.Store = (*Store)(nil)
+
+// InstrumentStore wraps s so its calls show up in minitwit_db_query_duration_seconds
+func InstrumentStore(s store.Store) *Store {
+	return &Store{next: s}
+}
+
+// done is deferred with the start of the call, err is read when it returns
+func done(function string, start time.Time, err *error) {
+	observe(dbDuration, function, start)
+	if *err != nil {
+		dbErrors.WithLabelValues(function).Inc()
+	}
+}
+
+func (s *Store) GetUserNameByUserID(userID int) (_ string, err error) {
+	defer done("GetUserNameByUserID", time.Now(), &err)
+	return s.next.GetUserNameByUserID(userID)
+}
+
+func (s *Store) GetUserIDByUsername(userName string) (_ int, err error) {
+	defer done("GetUserIDByUsername", time.Now(), &err)
+	return s.next.GetUserIDByUsername(userName)
+}
+
+func (s *Store) GetUserByUsername(userName string) (_ models.Users, err error) {
+	defer done("GetUserByUsername", time.Now(), &err)
+	return s.next.GetUserByUsername(userName)
+}
+
+func (s *Store) RegisterUser(userName string, email string, pwHash string) (err error) {
+	defer done("RegisterUser", time.Now(), &err)
+	return s.next.RegisterUser(userName, email, pwHash)
+}
+
+func (s *Store) UpdatePassword(userID int, pwHash string) (err error) {
+	defer done("UpdatePassword", time.Now(), &err)
+	return s.next.UpdatePassword(userID, pwHash)
+}
+
+func (s *Store) DeleteUser(userID int) (err error) {
+	defer done("DeleteUser", time.Now(), &err)
+	return s.next.DeleteUser(userID)
+}
+
+func (s *Store) GetPublicMessages(page store.Page) (_ []models.MessageUser, err error) {
+	defer done("GetPublicMessages", time.Now(), &err)
+	return s.next.GetPublicMessages(page)
+}
+
+func (s *Store) GetMyMessages(userID int, page store.Page) (_ []models.MessageUser, err error) {
+	defer done("GetMyMessages", time.Now(), &err)
+	return s.next.GetMyMessages(userID, page)
+}
+
+func (s *Store) GetUserMessages(pUserId int, page store.Page) (_ []models.MessageUser, err error) {
+	defer done("GetUserMessages", time.Now(), &err)
+	return s.next.GetUserMessages(pUserId, page)
+}
+
+func (s *Store) AddMessage(text string, authorID int) (err error) {
+	defer done("AddMessage", time.Now(), &err)
+	return s.next.AddMessage(text, authorID)
+}
+
+func (s *Store) DeleteMessage(messageID int, authorID int) (err error) {
+	defer done("DeleteMessage", time.Now(), &err)
+	return s.next.DeleteMessage(messageID, authorID)
+}
+
+func (s *Store) GetFollowing(userID int, limit int) (_ []models.Users, err error) {
+	defer done("GetFollowing", time.Now(), &err)
+	return s.next.GetFollowing(userID, limit)
+}
+
+func (s *Store) FollowUser(userID int, profileUserID int) (err error) {
+	defer done("FollowUser", time.Now(), &err)
+	return s.next.FollowUser(userID, profileUserID)
+}
+
+func (s *Store) UnfollowUser(userID int, profileUserID int) (err error) {
+	defer done("UnfollowUser", time.Now(), &err)
+	return s.next.UnfollowUser(userID, profileUserID)
+}
+
+func (s *Store) SetFlagged(messageID int, flagged bool, moderatorID int) (err error) {
+	defer done("SetFlagged", time.Now(), &err)
+	return s.next.SetFlagged(messageID, flagged, moderatorID)
+}
+
+func (s *Store) GetFlaggedMessages(limit int) (_ []models.FlaggedMessage, err error) {
+	defer done("GetFlaggedMessages", time.Now(), &err)
+	return s.next.GetFlaggedMessages(limit)
+}
+
+func (s *Store) GetLatest() (_ int, err error) {
+	defer done("GetLatest", time.Now(), &err)
+	return s.next.GetLatest()
+}
+
+func (s *Store) UpdateLatest(commandID int) (err error) {
+	defer done("UpdateLatest", time.Now(), &err)
+	return s.next.UpdateLatest(commandID)
+}
+
+func (s *Store) ClaimCommand(cmd models.Command, staleBefore time.Time) (_ models.Command, _ bool, err error) {
+	defer done("ClaimCommand", time.Now(), &err)
+	return s.next.ClaimCommand(cmd, staleBefore)
+}
+
+func (s *Store) CompleteCommand(cmd models.Command, latest bool) (err error) {
+	defer done("CompleteCommand", time.Now(), &err)
+	return s.next.CompleteCommand(cmd, latest)
+}
+
+func (s *Store) ReleaseCommand(commandID int) (err error) {
+	defer done("ReleaseCommand", time.Now(), &err)
+	return s.next.ReleaseCommand(commandID)
+}
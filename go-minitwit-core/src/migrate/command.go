@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const Usage = "usage: migrate up | down [steps] | status"
+
+// Command runs the `migrate` subcommand of the apps with args, the words after "migrate"
+func Command(db *gorm.DB, args []string, out io.Writer) error {
+	m, err := New(db)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, migration := range applied {
+			fmt.Fprintln(out, "applied", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("down %q: steps must be a positive number", args[1])
+			}
+		}
+		reverted, err := m.Down(steps)
+		for _, migration := range reverted {
+			fmt.Fprintln(out, "reverted", migration)
+		}
+		return err
+
+	case "status":
+		status, err := m.Status()
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%-40s %s\n", s.Migration, applied)
+		}
+		return err
+	}
+	return errors.New(Usage)
+}
+
+// Up applies the pending migrations of db, the apps call it on start-up
+func Up(db *gorm.DB) ([]Migration, error) {
+	m, err := New(db)
+	if err != nil {
+		return nil, err
+	}
+	return m.Up()
+}
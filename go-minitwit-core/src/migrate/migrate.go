@@ -0,0 +1,314 @@
+// Package migrate applies the versioned SQL migrations embedded in the binary
+// and records them in the schema_migrations table.
+package migrate
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var embedded embed.FS
+
+var (
+	ErrIrreversible = errors.New("migration has no down script")
+	ErrDirty        = errors.New("database has migrations this binary does not know")
+	// ErrDuplicateUsernames stops 0004_unique_username until an operator has
+	// renamed or deleted the accounts that share a name
+	ErrDuplicateUsernames = errors.New("users share a username")
+)
+
+// checks run before the up script of the migration of the same name. They
+// fail where the script would otherwise have to change data on its own.
+var checks = map[string]func(tx *gorm.DB) error{
+	"unique_username": duplicateUsernames,
+}
+
+// Migration is one NNNN_name.up.sql file and its optional .down.sql counterpart
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in dir of fsys, ordered by version
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			return nil, fmt.Errorf("%s: want NNNN_name.up.sql or NNNN_name.down.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%s has no up script", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// schemaMigration is a row of schema_migrations
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status is a known migration and whether it has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations embedded for the dialect of db
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Load(embedded, db.Dialector.Name())
+	if err != nil {
+		return nil, fmt.Errorf("loading %s migrations: %w", db.Dialector.Name(), err)
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration, each in a transaction of its own. Like
+// Status it fails with ErrDirty when the database is ahead of this binary.
+func (m *Migrator) Up() ([]Migration, error) {
+	if _, err := m.Status(); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range m.migrations {
+		ran := false
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			done, err := m.lock(tx, migration.Version)
+			if err != nil || done {
+				return err
+			}
+			if check, ok := checks[migration.Name]; ok {
+				if err := check(tx); err != nil {
+					return err
+				}
+			}
+			if err := exec(tx, migration.Up); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("applying %s: %w", migration, err)
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations, newest first
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	status, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(status) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := status[i].Migration
+		if !status[i].Applied {
+			continue
+		}
+		if migration.Down == "" {
+			return reverted, fmt.Errorf("reverting %s: %w", migration, ErrIrreversible)
+		}
+		ran := false
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			applied, err := m.lock(tx, migration.Version)
+			if err != nil || !applied {
+				return err
+			}
+			if err := exec(tx, migration.Down); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("reverting %s: %w", migration, err)
+		}
+		if ran {
+			reverted = append(reverted, migration)
+		}
+	}
+	return reverted, nil
+}
+
+// Status lists the known migrations in order. It fails with ErrDirty when the
+// database is ahead of this binary.
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := m.db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := map[int]schemaMigration{}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	status := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		row, ok := applied[migration.Version]
+		status[i] = Status{Migration: migration, Applied: ok, AppliedAt: row.AppliedAt}
+		delete(applied, migration.Version)
+	}
+	for version, row := range applied {
+		return status, fmt.Errorf("%w: %04d_%s", ErrDirty, version, row.Name)
+	}
+	return status, nil
+}
+
+func (m *Migrator) createTable() error {
+	if err := m.db.AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// lock serializes migrators of concurrently starting instances on Postgres and
+// reports whether version is applied, as seen while holding the lock
+func (m *Migrator) lock(tx *gorm.DB, version int) (applied bool, err error) {
+	if tx.Dialector.Name() == "postgres" {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", advisoryLockID).Error; err != nil {
+			return false, err
+		}
+	}
+	var count int64
+	err = tx.Model(&schemaMigration{}).Where("version = ?", version).Count(&count).Error
+	return count > 0, err
+}
+
+// duplicateUsernames lists the accounts that share a name, e.g.
+// "aa (user_id 1, 2, 4), bb (user_id 3, 5)"
+func duplicateUsernames(tx *gorm.DB) error {
+	var rows []struct {
+		Username string
+		UserID   int
+	}
+	err := tx.Table("users").Select("username, user_id").
+		Where("username IN (?)", tx.Table("users").Select("username").Group("username").Having("COUNT(*) > 1")).
+		Order("username, user_id").Find(&rows).Error
+	if err != nil {
+		return fmt.Errorf("looking for duplicate usernames: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var report []string
+	for i := 0; i < len(rows); {
+		name := rows[i].Username
+		var ids []string
+		for ; i < len(rows) && rows[i].Username == name; i++ {
+			ids = append(ids, strconv.Itoa(rows[i].UserID))
+		}
+		report = append(report, fmt.Sprintf("%s (user_id %s)", name, strings.Join(ids, ", ")))
+	}
+	return fmt.Errorf("%w: %s; rename or delete all but one account of each, then run `migrate up`",
+		ErrDuplicateUsernames, strings.Join(report, ", "))
+}
+
+// advisoryLockID is an arbitrary key shared by all migrators
+const advisoryLockID = 7_263_185_024
+
+// exec runs a script statement by statement, drivers and prepared statement
+// caches do not all accept several statements in one call
+func exec(tx *gorm.DB, script string) error {
+	for _, stmt := range statements(script) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("%w\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// statements splits a script on the semicolons that end a line and drops the
+// -- comment lines. The migrations need nothing smarter, keep them that way.
+func statements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
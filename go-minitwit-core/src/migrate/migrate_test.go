@@ -0,0 +1,176 @@
+package migrate
+
+import (
+	"errors"
+	"go-minitwit-core/src/db"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	var names [2][]string
+	for d, dialect := range []string{"postgres", "sqlite"} {
+		migrations, err := Load(embedded, dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range migrations {
+			names[d] = append(names[d], m.String())
+			if m.Version != i+1 {
+				t.Errorf("%s/%s: version %d, want %d, versions must be contiguous", dialect, m, m.Version, i+1)
+			}
+			if m.Down == "" {
+				t.Errorf("%s/%s has no down script", dialect, m)
+			}
+			if len(statements(m.Up)) == 0 {
+				t.Errorf("%s/%s: empty up script", dialect, m)
+			}
+		}
+	}
+	if !reflect.DeepEqual(names[0], names[1]) {
+		t.Errorf("postgres migrations %v and sqlite migrations %v differ", names[0], names[1])
+	}
+}
+
+func TestUpDownStatus(t *testing.T) {
+	gormDB, err := db.ConnectDB("sqlite::memory:", db.PoolOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(gormDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up()
+	if err != nil || len(applied) != len(m.migrations) {
+		t.Fatalf("Up = %v, %v, want all %d migrations", applied, err, len(m.migrations))
+	}
+	if again, err := m.Up(); err != nil || len(again) != 0 {
+		t.Errorf("second Up = %v, %v, want nothing to do", again, err)
+	}
+	if latest, err := db.NewGormStore(gormDB).GetLatest(); err != nil || latest != -1 {
+		t.Errorf("latest after Up = %d, %v, want -1", latest, err)
+	}
+
+	// back to 0002, which takes 0003_http_sessions with it
+	steps := len(m.migrations) - 2
+	reverted, err := m.Down(steps)
+	if err != nil || len(reverted) != steps || reverted[0].Version != len(m.migrations) || reverted[steps-1].Name != "http_sessions" {
+		t.Fatalf("Down(%d) = %v, %v, want the newest migrations down to http_sessions", steps, reverted, err)
+	}
+	if gormDB.Migrator().HasTable("http_sessions") {
+		t.Errorf("http_sessions still exists after Down(%d)", steps)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status[1].Applied || status[1].AppliedAt.IsZero() || status[2].Applied || status[len(status)-1].Applied {
+		t.Errorf("Status = %+v, want the first two applied", status)
+	}
+
+	if err := gormDB.Create(&schemaMigration{Version: 999, Name: "from_the_future"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Status(); !errors.Is(err, ErrDirty) {
+		t.Errorf("Status with an unknown version: err = %v, want ErrDirty", err)
+	}
+	if applied, err := m.Up(); !errors.Is(err, ErrDirty) || len(applied) != 0 {
+		t.Errorf("Up with an unknown version = %v, %v, want ErrDirty and nothing applied", applied, err)
+	}
+}
+
+func TestUniqueUsernameReportsDuplicates(t *testing.T) {
+	gormDB, err := db.ConnectDB("sqlite::memory:", db.PoolOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(gormDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the schema as it was before the unique index
+	all := m.migrations
+	i := slices.IndexFunc(all, func(m Migration) bool { return m.Name == "unique_username" })
+	m.migrations = all[:i]
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"aa", "aa", "bb", "aa", "cc", "cc"} {
+		if err := gormDB.Exec("INSERT INTO users (username, email, pw_hash) VALUES (?, 'x@y.z', 'x')", name).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.migrations = all
+	_, err = m.Up()
+	if !errors.Is(err, ErrDuplicateUsernames) || !strings.Contains(err.Error(), "aa (user_id 1, 2, 4), cc (user_id 5, 6)") {
+		t.Fatalf("Up with duplicates: err = %v, want ErrDuplicateUsernames listing aa and cc", err)
+	}
+	var names []string
+	if err := gormDB.Table("users").Order("user_id").Pluck("username", &names).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"aa", "aa", "bb", "aa", "cc", "cc"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("usernames = %v, want them untouched", names)
+	}
+
+	// once the operator has resolved them the migration goes in
+	if err := gormDB.Exec("UPDATE users SET username = username || '_' || user_id WHERE user_id IN (2, 4, 6)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gormDB.Exec("INSERT INTO users (username, email, pw_hash) VALUES ('bb', 'x@y.z', 'x')").Error; err == nil {
+		t.Error("a second bb was stored, the index is not unique")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_second.up.sql":   {Data: []byte("B;")},
+		"sql/0001_first.up.sql":    {Data: []byte("A;")},
+		"sql/0001_first.down.sql":  {Data: []byte("a;")},
+		"bad/0001_first.up.sql":    {Data: []byte("A;")},
+		"bad/0001_other.down.sql":  {Data: []byte("a;")},
+		"nameless/first.up.sql":    {Data: []byte("A;")},
+		"downonly/0001_x.down.sql": {Data: []byte("a;")},
+	}
+
+	migrations, err := Load(fsys, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{{1, "first", "A;", "a;"}, {2, "second", "B;", ""}}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("Load = %+v, want %+v", migrations, want)
+	}
+
+	for _, dir := range []string{"bad", "nameless", "downonly"} {
+		if _, err := Load(fsys, dir); err == nil {
+			t.Errorf("Load(%s) succeeded", dir)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := `-- a comment
+CREATE TABLE t (
+  id INTEGER -- trailing comments stay
+);
+
+INSERT INTO t VALUES (1);
+DROP TABLE t`
+
+	got := statements(script)
+	if len(got) != 3 || !strings.HasPrefix(got[0], "CREATE TABLE t (") || got[1] != "INSERT INTO t VALUES (1);" || got[2] != "DROP TABLE t" {
+		t.Errorf("statements = %q", got)
+	}
+}
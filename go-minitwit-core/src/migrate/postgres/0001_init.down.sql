@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS latest;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS followers;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,36 @@
+-- The tables of database/schema.sql. IF NOT EXISTS lets databases that were
+-- created from that file adopt the migrations without changes.
+CREATE TABLE IF NOT EXISTS users (
+  user_id SERIAL PRIMARY KEY,
+  username TEXT NOT NULL,
+  email TEXT NOT NULL,
+  pw_hash TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS followers (
+  who_id INTEGER,
+  whom_id INTEGER,
+  FOREIGN KEY (who_id) REFERENCES users (user_id),
+  FOREIGN KEY (whom_id) REFERENCES users (user_id),
+  PRIMARY KEY (who_id, whom_id)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+  message_id SERIAL PRIMARY KEY,
+  author_id INTEGER NOT NULL,
+  text TEXT NOT NULL,
+  pub_date TIMESTAMP NOT NULL,
+  flagged INTEGER NOT NULL,
+  FOREIGN KEY (author_id) REFERENCES users (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS latest (
+  id INTEGER PRIMARY KEY,
+  value INTEGER NOT NULL
+);
+
+INSERT INTO latest (id, value) VALUES (1, -1) ON CONFLICT (id) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS username_index ON users (username);
+CREATE INDEX IF NOT EXISTS pub_date_index ON messages (pub_date);
+CREATE INDEX IF NOT EXISTS author_id_index ON messages (author_id);
@@ -0,0 +1,9 @@
+-- UI sessions of SESSION_BACKEND=postgres, the same table the backend used to
+-- create with gorm's AutoMigrate
+CREATE TABLE IF NOT EXISTS http_sessions (
+  id TEXT PRIMARY KEY,
+  data BYTEA,
+  expires_on TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_http_sessions_expires_on ON http_sessions (expires_on);
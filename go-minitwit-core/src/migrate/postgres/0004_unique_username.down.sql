@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS username_unique_index;
+CREATE INDEX IF NOT EXISTS username_index ON users (username);
@@ -0,0 +1,6 @@
+-- registrations used to check for the name and then insert, so concurrent ones
+-- could store a name twice. Such duplicates are not resolved here: the
+-- migrator refuses to run this script while there are any (see
+-- duplicateUsernames) and lists them for the operator to rename or delete.
+DROP INDEX IF EXISTS username_index;
+CREATE UNIQUE INDEX IF NOT EXISTS username_unique_index ON users (username);
@@ -0,0 +1,13 @@
+-- who flagged or unflagged which message and when, messages.flagged holds the
+-- current state
+CREATE TABLE IF NOT EXISTS moderation_log (
+  id SERIAL PRIMARY KEY,
+  message_id INTEGER NOT NULL,
+  moderator_id INTEGER NOT NULL,
+  flagged INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  FOREIGN KEY (message_id) REFERENCES messages (message_id),
+  FOREIGN KEY (moderator_id) REFERENCES users (user_id)
+);
+
+CREATE INDEX IF NOT EXISTS moderation_log_message_index ON moderation_log (message_id);
@@ -0,0 +1,11 @@
+-- the outcome of every simulator command by its ?latest= id, so that a retried
+-- command is answered from here instead of running twice; status 0 while it runs
+CREATE TABLE IF NOT EXISTS command_log (
+  command_id INTEGER PRIMARY KEY,
+  method TEXT NOT NULL,
+  path TEXT NOT NULL,
+  status INTEGER NOT NULL,
+  content_type TEXT NOT NULL,
+  body TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS author_pub_date_index;
@@ -0,0 +1,2 @@
+-- serves the home timeline: newest messages of a set of authors
+CREATE INDEX IF NOT EXISTS author_pub_date_index ON messages (author_id, pub_date DESC);
@@ -0,0 +1,8 @@
+-- UI sessions of SESSION_BACKEND=postgres
+CREATE TABLE IF NOT EXISTS http_sessions (
+  id TEXT PRIMARY KEY,
+  data BLOB,
+  expires_on DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_http_sessions_expires_on ON http_sessions (expires_on);
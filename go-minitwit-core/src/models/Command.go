@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Command is a simulator command in the command log, keyed by its ?latest= id.
+// Status is 0 while the command runs and the response status afterwards.
+type Command struct {
+	CommandID   int       `gorm:"column:command_id;primaryKey;autoIncrement:false"`
+	Method      string    `gorm:"column:method;not null"`
+	Path        string    `gorm:"column:path;not null"`
+	Status      int       `gorm:"column:status;not null"`
+	ContentType string    `gorm:"column:content_type;not null"`
+	Body        string    `gorm:"column:body;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamp;not null"`
+}
+
+func (Command) TableName() string {
+	return "command_log"
+}
@@ -0,0 +1,8 @@
+package models
+
+// DeleteData is the body of /api/delete, without a message_id the whole account goes
+type DeleteData struct {
+	User      string `json:"user"`
+	Pwd       string `json:"pwd"`
+	MessageID int    `json:"message_id"`
+}
@@ -0,0 +1,17 @@
+package models
+
+type FilteredMsg struct {
+	MessageID int    `json:"message_id"`
+	Content   string `json:"content"`
+	// PubDate is in unix seconds, PubDateRFC3339 is only filled in on request
+	PubDate        int64  `json:"pub_date"`
+	PubDateRFC3339 string `json:"pub_date_rfc3339,omitempty"`
+	User           string `json:"user"`
+}
+
+// FilteredFlaggedMsg is the API form of a FlaggedMessage, FlaggedAt is in unix seconds
+type FilteredFlaggedMsg struct {
+	FilteredMsg
+	FlaggedBy string `json:"flagged_by"`
+	FlaggedAt int64  `json:"flagged_at"`
+}
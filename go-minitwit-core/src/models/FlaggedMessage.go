@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// FlaggedMessage is a flagged message with the moderator who flagged it last.
+// FlaggedBy is empty for messages flagged outside the moderation log.
+type FlaggedMessage struct {
+	MessageUser
+	FlaggedBy string
+	FlaggedAt time.Time
+}
@@ -0,0 +1,5 @@
+package models
+
+type MessageData struct {
+	Content string `json:"content"`
+}
@@ -11,4 +11,7 @@ type MessageUI struct {
 	Username     string
 	Profile_link string
 	Gravatar     string
+	// FlaggedBy and FlaggedAt are only set on the flagged messages page
+	FlaggedBy string
+	FlaggedAt string
 }
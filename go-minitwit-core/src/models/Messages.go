@@ -4,9 +4,9 @@ import "time"
 
 type Messages struct {
 	MessageID int       `gorm:"column:message_id;primaryKey"`
-	AuthorID  int       `gorm:"column:author_id;not null"`
+	AuthorID  int       `gorm:"column:author_id;not null;index:author_pub_date_index,priority:1"`
 	Content   string    `gorm:"column:text;not null"`
-	PubDate   time.Time `gorm:"column:pub_date;type:timestamp"`
+	PubDate   time.Time `gorm:"column:pub_date;type:timestamp;index:author_pub_date_index,priority:2,sort:desc"`
 	Flagged   int       `gorm:"column:flagged;"`
 }
 
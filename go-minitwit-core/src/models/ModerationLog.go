@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ModerationLog is one flag or unflag of a message by a moderator
+type ModerationLog struct {
+	ID          int       `gorm:"column:id;primaryKey"`
+	MessageID   int       `gorm:"column:message_id;not null;index:moderation_log_message_index"`
+	ModeratorID int       `gorm:"column:moderator_id;not null"`
+	Flagged     int       `gorm:"column:flagged;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamp;not null"`
+}
+
+func (ModerationLog) TableName() string {
+	return "moderation_log"
+}
@@ -2,7 +2,7 @@ package models
 
 type Users struct {
 	UserID   int    `gorm:"column:user_id;primaryKey"`
-	Username string `gorm:"column:username;not null;index"`
+	Username string `gorm:"column:username;not null;uniqueIndex:username_unique_index"`
 	Email    string `gorm:"column:email;not null"`
 	Pwd      string `gorm:"column:pw_hash;not null"`
 }
@@ -0,0 +1,331 @@
+// Package openapi holds the OpenAPI document of the simulator API and checks
+// requests against it, so malformed requests are rejected before they reach
+// the handlers of either app.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-minitwit-core/src/apierror"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Document is the OpenAPI 3 document served at /api/openapi.json
+//
+//go:embed openapi.json
+var Document []byte
+
+// MaxBodyBytes is the largest request body Validate reads. The simulator's
+// bodies are a short message or a few names, far below it.
+const MaxBodyBytes = 16 << 10
+
+// Schema is the subset of JSON Schema the document uses
+type Schema struct {
+	Ref           string             `json:"$ref"`
+	Type          string             `json:"type"`
+	Properties    map[string]*Schema `json:"properties"`
+	Required      []string           `json:"required"`
+	MinProperties int                `json:"minProperties"`
+	Enum          []string           `json:"enum"`
+	Minimum       *float64           `json:"minimum"`
+	Items         *Schema            `json:"items"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type document struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths map[string]map[string]struct {
+		Parameters  []parameter `json:"parameters"`
+		RequestBody *struct {
+			Content map[string]struct {
+				Schema *Schema `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+	} `json:"paths"`
+	Components struct {
+		Parameters map[string]parameter `json:"parameters"`
+		Schemas    map[string]*Schema   `json:"schemas"`
+	} `json:"components"`
+}
+
+// operation is one method of a path, its "{name}" segments are path parameters
+type operation struct {
+	method     string
+	segments   []string
+	parameters []parameter
+	body       *Schema
+}
+
+// Validator checks requests against the operations of a document
+type Validator struct {
+	prefix     string
+	operations []operation
+}
+
+// Default is the validator for Document, which is tested to load
+func Default() *Validator {
+	v, err := Load(Document)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Load reads an OpenAPI document and resolves its references
+func Load(doc []byte) (*Validator, error) {
+	var d document
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, fmt.Errorf("reading OpenAPI document: %w", err)
+	}
+	v := &Validator{}
+	if len(d.Servers) > 0 {
+		v.prefix = strings.TrimRight(d.Servers[0].URL, "/")
+	}
+
+	for path, methods := range d.Paths {
+		for method, op := range methods {
+			o := operation{method: strings.ToUpper(method), segments: strings.Split(strings.Trim(path, "/"), "/")}
+			for _, p := range op.Parameters {
+				p, err := d.parameter(p)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", o.method, path, err)
+				}
+				o.parameters = append(o.parameters, p)
+			}
+			if op.RequestBody != nil {
+				body, err := d.resolve(op.RequestBody.Content["application/json"].Schema)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", o.method, path, err)
+				}
+				o.body = body
+			}
+			v.operations = append(v.operations, o)
+		}
+	}
+	return v, nil
+}
+
+func (d *document) parameter(p parameter) (parameter, error) {
+	if p.Ref != "" {
+		ref, ok := d.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+		if !ok {
+			return p, fmt.Errorf("unknown parameter %s", p.Ref)
+		}
+		p = ref
+	}
+	schema, err := d.resolve(p.Schema)
+	if err != nil {
+		return p, fmt.Errorf("parameter %s: %w", p.Name, err)
+	}
+	p.Schema = schema
+	return p, nil
+}
+
+// resolve replaces the $refs in s with the schemas they point to
+func (d *document) resolve(s *Schema) (*Schema, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if s.Ref != "" {
+		ref, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		return d.resolve(ref)
+	}
+	for name, p := range s.Properties {
+		resolved, err := d.resolve(p)
+		if err != nil {
+			return nil, err
+		}
+		s.Properties[name] = resolved
+	}
+	items, err := d.resolve(s.Items)
+	if err != nil {
+		return nil, err
+	}
+	s.Items = items
+	return s, nil
+}
+
+// Validate checks the parameters and the JSON body of r against its operation.
+// Requests the document does not describe are left to the router. A body that
+// is not JSON is apierror.ErrMalformedBody, any other mismatch
+// apierror.ErrInvalidRequest. The body is put back for the handlers.
+func (v *Validator) Validate(r *http.Request) error {
+	path, ok := strings.CutPrefix(r.URL.Path, v.prefix)
+	if !ok {
+		return nil
+	}
+	op, pathValues, ok := v.match(r.Method, strings.Split(strings.Trim(path, "/"), "/"))
+	if !ok {
+		return nil
+	}
+
+	query := r.URL.Query()
+	for _, p := range op.parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = pathValues[p.Name]
+		case "query":
+			present = query.Has(p.Name)
+			value = query.Get(p.Name)
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				return fmt.Errorf("%w: %s parameter %q is required", apierror.ErrInvalidRequest, p.In, p.Name)
+			}
+			continue
+		}
+		if err := checkParameter(p.Schema, value); err != nil {
+			return fmt.Errorf("%w: %s parameter %q %s", apierror.ErrInvalidRequest, p.In, p.Name, err)
+		}
+	}
+
+	if op.body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: more than %d bytes", apierror.ErrBodyTooLarge, tooLarge.Limit)
+	}
+	if err != nil {
+		return fmt.Errorf("reading request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("%w: %v", apierror.ErrMalformedBody, err)
+	}
+	return check(op.body, value, "request body")
+}
+
+func (v *Validator) match(method string, segments []string) (operation, map[string]string, bool) {
+next:
+	for _, op := range v.operations {
+		if op.method != method || len(op.segments) != len(segments) {
+			continue
+		}
+		values := map[string]string{}
+		for i, s := range op.segments {
+			if name, ok := strings.CutPrefix(s, "{"); ok {
+				values[strings.TrimSuffix(name, "}")] = segments[i]
+			} else if s != segments[i] {
+				continue next
+			}
+		}
+		return op, values, true
+	}
+	return operation{}, nil, false
+}
+
+// checkParameter checks a path or query value, the error completes "parameter x ..."
+func checkParameter(s *Schema, value string) error {
+	if s == nil {
+		return nil
+	}
+	switch s.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		if s.Minimum != nil && float64(n) < *s.Minimum {
+			return fmt.Errorf("must be at least %v", *s.Minimum)
+		}
+	case "string":
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+	}
+	return nil
+}
+
+// check checks a decoded JSON value, where names it in the error
+func check(s *Schema, value any, where string) error {
+	if s == nil {
+		return nil
+	}
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s %s", apierror.ErrInvalidRequest, where, fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return invalid("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return invalid("is missing property %q", name)
+			}
+		}
+		if len(obj) < s.MinProperties {
+			return invalid("has %d properties, want at least %d", len(obj), s.MinProperties)
+		}
+		for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+			if v, ok := obj[name]; ok {
+				if err := check(s.Properties[name], v, fmt.Sprintf("%s property %q", where, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return invalid("must be an array")
+		}
+		for i, item := range items {
+			if err := check(s.Items, item, fmt.Sprintf("%s item %d", where, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return invalid("must be a string")
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return invalid("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return invalid("must be an integer")
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return invalid("must be an integer")
+		}
+		if s.Minimum != nil && float64(i) < *s.Minimum {
+			return invalid("must be at least %v", *s.Minimum)
+		}
+	}
+	return nil
+}
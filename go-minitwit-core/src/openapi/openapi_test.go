@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"go-minitwit-core/src/apierror"
+	"go-minitwit-core/src/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonFields is the JSON form of the fields of t, embedded structs flattened
+func jsonFields(t reflect.Type) map[string]string {
+	fields := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			for name, typ := range jsonFields(f.Type) {
+				fields[name] = typ
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch f.Type.Kind() {
+		case reflect.String:
+			fields[name] = "string"
+		case reflect.Int, reflect.Int64:
+			fields[name] = "integer"
+		default:
+			fields[name] = f.Type.Kind().String()
+		}
+	}
+	return fields
+}
+
+func TestDocumentMatchesTypes(t *testing.T) {
+	var d document
+	if err := json.Unmarshal(Document, &d); err != nil {
+		t.Fatal(err)
+	}
+
+	types := map[string]any{
+		"RegisterData":       models.RegisterData{},
+		"MessageData":        models.MessageData{},
+		"FollowData":         models.FollowData{},
+		"DeleteData":         models.DeleteData{},
+		"FilteredMsg":        models.FilteredMsg{},
+		"FilteredFlaggedMsg": models.FilteredFlaggedMsg{},
+		"Error":              apierror.Error{},
+	}
+	for name, v := range types {
+		schema, ok := d.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing", name)
+			continue
+		}
+		documented := map[string]string{}
+		for prop, s := range schema.Properties {
+			documented[prop] = s.Type
+		}
+		if want := jsonFields(reflect.TypeOf(v)); !reflect.DeepEqual(documented, want) {
+			t.Errorf("schema %s = %v, the Go type has %v", name, documented, want)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	v := Default()
+	if len(v.operations) == 0 || v.prefix != "/api" {
+		t.Errorf("Default() = %d operations under %q, want some under /api", len(v.operations), v.prefix)
+	}
+}
+
+func TestLoadUnknownRef(t *testing.T) {
+	doc := `{"paths": {"/x": {"post": {"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Nope"}}}}}}}}`
+	if _, err := Load([]byte(doc)); err == nil {
+		t.Error("Load with an unknown $ref succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v := Default()
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		want   error
+		msg    string
+	}{
+		{http.MethodGet, "/api/msgs?no=10&latest=3&date_format=rfc3339", "", nil, ""},
+		{http.MethodGet, "/api/msgs?no=many", "", apierror.ErrInvalidRequest, `invalid request: query parameter "no" must be an integer`},
+		{http.MethodGet, "/api/msgs?no=0", "", apierror.ErrInvalidRequest, `invalid request: query parameter "no" must be at least 1`},
+		{http.MethodGet, "/api/msgs/aa?date_format=iso", "", apierror.ErrInvalidRequest, `invalid request: query parameter "date_format" must be one of unix, rfc3339`},
+		{http.MethodGet, "/api/msgs?latest=abc", "", apierror.ErrInvalidRequest, `invalid request: query parameter "latest" must be an integer`},
+		{http.MethodPut, "/api/flagged/x", "", apierror.ErrInvalidRequest, `invalid request: path parameter "message_id" must be an integer`},
+		{http.MethodPost, "/api/msgs/aa", `{"content": "hi"}`, nil, ""},
+		{http.MethodPost, "/api/msgs/aa", `{"content": 5}`, apierror.ErrInvalidRequest, `invalid request: request body property "content" must be a string`},
+		{http.MethodPost, "/api/msgs/aa", `{"text": "hi"}`, apierror.ErrInvalidRequest, `invalid request: request body is missing property "content"`},
+		{http.MethodPost, "/api/msgs/aa", `{"content":`, apierror.ErrMalformedBody, ""},
+		{http.MethodPost, "/api/msgs/aa", ``, apierror.ErrMalformedBody, ""},
+		{http.MethodPost, "/api/register", `["aa"]`, apierror.ErrInvalidRequest, `invalid request: request body must be an object`},
+		{http.MethodPost, "/api/fllws/aa", `{}`, apierror.ErrInvalidRequest, `invalid request: request body has 0 properties, want at least 1`},
+		{http.MethodPost, "/api/fllws/aa", `{"unfollow": "bb"}`, nil, ""},
+		// left to the router
+		{http.MethodGet, "/api/nothing?no=many", "", nil, ""},
+		{http.MethodPatch, "/api/msgs/aa", `{`, nil, ""},
+		{http.MethodGet, "/public?no=many", "", nil, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		err := v.Validate(req)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("%s %s %s: err = %v, want %v", tt.method, tt.target, tt.body, err, tt.want)
+			continue
+		}
+		if tt.msg != "" && err.Error() != tt.msg {
+			t.Errorf("%s %s %s: err = %q, want %q", tt.method, tt.target, tt.body, err, tt.msg)
+		}
+	}
+}
+
+func TestValidateBodyLimit(t *testing.T) {
+	content := strings.Repeat("x", MaxBodyBytes)
+	req := httptest.NewRequest(http.MethodPost, "/api/msgs/aa", strings.NewReader(`{"content": "`+content+`"}`))
+	if err := Default().Validate(req); !errors.Is(err, apierror.ErrBodyTooLarge) {
+		t.Errorf("body over %d bytes: err = %v, want ErrBodyTooLarge", MaxBodyBytes, err)
+	}
+	if e := apierror.For(apierror.ErrBodyTooLarge); e.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("status of a too large body = %d, want 413", e.Status)
+	}
+}
+
+func TestValidateKeepsBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(`{"username": "aa", "email": "a@a.a", "pwd": "a"}`))
+	if err := Default().Validate(req); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"username": "aa", "email": "a@a.a", "pwd": "a"}` {
+		t.Errorf("body after Validate = %q", body)
+	}
+}
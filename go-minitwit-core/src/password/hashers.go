@@ -0,0 +1,203 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var errMalformed = errors.New("malformed password hash")
+
+var b64 = base64.RawStdEncoding
+
+func salt(n int) ([]byte, error) {
+	s := make([]byte, n)
+	if _, err := rand.Read(s); err != nil {
+		return nil, fmt.Errorf("reading salt: %w", err)
+	}
+	return s, nil
+}
+
+// Bcrypt uses the standard "$2a$<cost>$..." encoding
+type Bcrypt struct {
+	// Cost defaults to bcrypt.DefaultCost
+	Cost int
+}
+
+func (Bcrypt) Name() string { return "bcrypt" }
+
+func (b Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost())
+	return string(hash), err
+}
+
+func (Bcrypt) Verify(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (Bcrypt) Owns(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (b Bcrypt) UpToDate(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return b.Owns(encoded) && err == nil && cost == b.cost()
+}
+
+// Argon2id uses the PHC encoding "$argon2id$v=19$m=<KiB>,t=<time>,p=<threads>$<salt>$<hash>"
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// RFC 9106 second recommended option, scaled down to 64 MiB
+var defaultArgon2id = Argon2id{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+const argon2KeyLen = 32
+
+func (Argon2id) Name() string { return "argon2id" }
+
+func (a Argon2id) params() Argon2id {
+	if a == (Argon2id{}) {
+		return defaultArgon2id
+	}
+	return a
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	a = a.params()
+	s, err := salt(16)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), s, a.Time, a.Memory, a.Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Time, a.Threads, b64.EncodeToString(s), b64.EncodeToString(key)), nil
+}
+
+// parseArgon2id splits an encoded value into its parameters, salt and key
+func parseArgon2id(encoded string) (p Argon2id, s []byte, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errMalformed
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errMalformed
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, errMalformed
+	}
+	if s, err = b64.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, errMalformed
+	}
+	if key, err = b64.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, errMalformed
+	}
+	return p, s, key, nil
+}
+
+func (Argon2id) Verify(password string, encoded string) (bool, error) {
+	p, s, want, err := parseArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	got := argon2.IDKey([]byte(password), s, p.Time, p.Memory, p.Threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+func (Argon2id) Owns(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a Argon2id) UpToDate(encoded string) bool {
+	p, _, key, err := parseArgon2id(encoded)
+	return a.Owns(encoded) && err == nil && p == a.params() && len(key) == argon2KeyLen
+}
+
+// PBKDF2 uses "$pbkdf2-sha256$i=<iterations>$<salt>$<hash>"
+type PBKDF2 struct {
+	// Iterations defaults to the OWASP recommendation for PBKDF2-HMAC-SHA256
+	Iterations int
+}
+
+const (
+	defaultPBKDF2Iterations = 600000
+	pbkdf2KeyLen            = 32
+)
+
+func (PBKDF2) Name() string { return "pbkdf2" }
+
+func (p PBKDF2) iterations() int {
+	if p.Iterations == 0 {
+		return defaultPBKDF2Iterations
+	}
+	return p.Iterations
+}
+
+func (p PBKDF2) Hash(password string) (string, error) {
+	s, err := salt(16)
+	if err != nil {
+		return "", err
+	}
+	key := pbkdf2.Key([]byte(password), s, p.iterations(), pbkdf2KeyLen, sha256.New)
+	return fmt.Sprintf("$pbkdf2-sha256$i=%d$%s$%s", p.iterations(), b64.EncodeToString(s), b64.EncodeToString(key)), nil
+}
+
+// parsePBKDF2 splits an encoded value into its iterations, salt and key
+func parsePBKDF2(encoded string) (iterations int, s []byte, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return 0, nil, nil, errMalformed
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "i=%d", &iterations); err != nil || iterations <= 0 {
+		return 0, nil, nil, errMalformed
+	}
+	if s, err = b64.DecodeString(parts[3]); err != nil {
+		return 0, nil, nil, errMalformed
+	}
+	if key, err = b64.DecodeString(parts[4]); err != nil {
+		return 0, nil, nil, errMalformed
+	}
+	return iterations, s, key, nil
+}
+
+func (PBKDF2) Verify(password string, encoded string) (bool, error) {
+	iterations, s, want, err := parsePBKDF2(encoded)
+	if err != nil {
+		return false, err
+	}
+	got := pbkdf2.Key([]byte(password), s, iterations, len(want), sha256.New)
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+func (PBKDF2) Owns(encoded string) bool {
+	return strings.HasPrefix(encoded, "$pbkdf2-sha256$")
+}
+
+func (p PBKDF2) UpToDate(encoded string) bool {
+	iterations, _, key, err := parsePBKDF2(encoded)
+	return p.Owns(encoded) && err == nil && iterations == p.iterations() && len(key) == pbkdf2KeyLen
+}
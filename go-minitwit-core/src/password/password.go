@@ -0,0 +1,129 @@
+// Package password hashes and verifies the pw_hash column.
+//
+// Every stored value carries its format: bcrypt hashes start with "$2a$"/"$2b$",
+// the others use a PHC-style "$<id>$..." prefix. Anything else is treated as a
+// legacy plaintext password, which is what the reference Minitwit stores. Values
+// in a format or with parameters (cost, iterations, ...) other than the
+// configured ones are reported as needing a rehash, so they can be upgraded on
+// the next successful login. Nothing is ever rehashed into plaintext.
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDollarPrefix is returned by Plaintext.Hash, such a value would be taken
+// for a hash on the next login
+var ErrDollarPrefix = errors.New("plaintext passwords must not start with '$'")
+
+// Hasher is one hashing scheme
+type Hasher interface {
+	// Name is the value used to select the hasher, e.g. in PASSWORD_HASHER
+	Name() string
+	// Hash encodes password in this hasher's format
+	Hash(password string) (string, error)
+	// Verify checks password against a value produced by Hash
+	Verify(password string, encoded string) (bool, error)
+	// Owns reports whether encoded is in this hasher's format
+	Owns(encoded string) bool
+	// UpToDate reports whether encoded is in this hasher's format and was
+	// produced with its current parameters
+	UpToDate(encoded string) bool
+}
+
+// DefaultHasher is used when no hasher is configured
+const DefaultHasher = "bcrypt"
+
+// Policy hashes new passwords with the current hasher and verifies values in any known format
+type Policy struct {
+	current Hasher
+	known   []Hasher
+}
+
+// New returns a policy hashing with the default parameters of the hasher called name
+func New(name string) (*Policy, error) {
+	if name == "" {
+		name = DefaultHasher
+	}
+
+	for _, h := range []Hasher{Bcrypt{}, Argon2id{}, PBKDF2{}, Plaintext{}} {
+		if h.Name() == name {
+			return NewPolicy(h), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown password hasher %q (want bcrypt, argon2id, pbkdf2 or plaintext)", name)
+}
+
+// NewPolicy returns a policy hashing with h, e.g. a Bcrypt with a custom cost
+func NewPolicy(h Hasher) *Policy {
+	return &Policy{current: h, known: []Hasher{Bcrypt{}, Argon2id{}, PBKDF2{}}}
+}
+
+// Name of the hasher used for new passwords
+func (p *Policy) Name() string {
+	return p.current.Name()
+}
+
+func (p *Policy) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+// Verify checks password against the stored value. needsRehash is true when the
+// password matched but the stored value is not in the current format or has
+// other parameters. A plaintext policy never asks for one, that would turn
+// hashes back into passwords.
+//
+// The format is told by the prefix only, and the reference Minitwit let users
+// register plaintext passwords such as "$2a$x". A value the matching hasher
+// cannot parse is therefore compared as plaintext, and rehashed on a match.
+func (p *Policy) Verify(password string, stored string) (ok bool, needsRehash bool, err error) {
+	h := p.hasherFor(stored)
+
+	ok, err = h.Verify(password, stored)
+	if err != nil {
+		ok, _ = Plaintext{}.Verify(password, stored)
+	}
+	if !ok {
+		return false, false, nil
+	}
+	if _, plaintext := p.current.(Plaintext); plaintext {
+		return true, false, nil
+	}
+	return true, err != nil || !p.current.UpToDate(stored), nil
+}
+
+func (p *Policy) hasherFor(stored string) Hasher {
+	for _, h := range p.known {
+		if h.Owns(stored) {
+			return h
+		}
+	}
+	return Plaintext{}
+}
+
+// Plaintext keeps the password as is, like the other Minitwit implementations
+type Plaintext struct{}
+
+func (Plaintext) Name() string { return "plaintext" }
+
+func (Plaintext) Hash(password string) (string, error) {
+	if strings.HasPrefix(password, "$") {
+		return "", ErrDollarPrefix
+	}
+	return password, nil
+}
+
+func (Plaintext) Verify(password string, encoded string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(password), []byte(encoded)) == 1, nil
+}
+
+func (Plaintext) Owns(encoded string) bool {
+	return !strings.HasPrefix(encoded, "$")
+}
+
+func (p Plaintext) UpToDate(encoded string) bool {
+	return p.Owns(encoded)
+}
@@ -0,0 +1,102 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cheap parameters, the defaults take ~100ms per hash
+var testHashers = []Hasher{Bcrypt{Cost: 4}, Argon2id{Time: 1, Memory: 64, Threads: 1}, PBKDF2{Iterations: 1000}, Plaintext{}}
+
+func TestRoundTrip(t *testing.T) {
+	for _, h := range testHashers {
+		p := NewPolicy(h)
+		stored, err := p.Hash("secret")
+		if err != nil {
+			t.Fatalf("%s: %v", h.Name(), err)
+		}
+		if !h.Owns(stored) {
+			t.Errorf("%s does not own its own hash %q", h.Name(), stored)
+		}
+		if ok, needsRehash, err := p.Verify("secret", stored); !ok || needsRehash || err != nil {
+			t.Errorf("%s: Verify(secret) = %v, %v, %v, want true, false, nil", h.Name(), ok, needsRehash, err)
+		}
+		if ok, _, _ := p.Verify("wrong", stored); ok {
+			t.Errorf("%s: wrong password verified", h.Name())
+		}
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	current := NewPolicy(Bcrypt{Cost: 4})
+	for _, h := range testHashers[1:] {
+		stored, err := h.Hash("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, needsRehash, err := current.Verify("secret", stored)
+		if !ok || !needsRehash || err != nil {
+			t.Errorf("%s value under bcrypt policy: Verify = %v, %v, %v, want true, true, nil", h.Name(), ok, needsRehash, err)
+		}
+	}
+}
+
+func TestRehashOnNewParameters(t *testing.T) {
+	for _, tt := range []struct {
+		old, current Hasher
+	}{
+		{Bcrypt{Cost: 4}, Bcrypt{Cost: 5}},
+		{Argon2id{Time: 1, Memory: 64, Threads: 1}, Argon2id{Time: 2, Memory: 64, Threads: 1}},
+		{PBKDF2{Iterations: 1000}, PBKDF2{Iterations: 2000}},
+	} {
+		stored, err := tt.old.Hash("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, needsRehash, err := NewPolicy(tt.current).Verify("secret", stored); !ok || !needsRehash || err != nil {
+			t.Errorf("%+v value under %+v: Verify = %v, %v, %v, want true, true, nil", tt.old, tt.current, ok, needsRehash, err)
+		}
+	}
+}
+
+func TestNoRehashIntoPlaintext(t *testing.T) {
+	current := NewPolicy(Plaintext{})
+	for _, h := range testHashers[:3] {
+		stored, err := h.Hash("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, needsRehash, err := current.Verify("secret", stored); !ok || needsRehash || err != nil {
+			t.Errorf("%s value under plaintext policy: Verify = %v, %v, %v, want true, false, nil", h.Name(), ok, needsRehash, err)
+		}
+	}
+}
+
+func TestLegacyPlaintextWithHashPrefix(t *testing.T) {
+	for _, stored := range []string{"$2a$short", "$argon2id$v=19$garbage", "$pbkdf2-sha256$x"} {
+		for _, current := range []Hasher{Bcrypt{Cost: 4}, Plaintext{}} {
+			p := NewPolicy(current)
+			ok, needsRehash, err := p.Verify(stored, stored)
+			wantRehash := current.Name() != "plaintext"
+			if !ok || needsRehash != wantRehash || err != nil {
+				t.Errorf("%s: Verify(%q) = %v, %v, %v, want true, %v, nil", current.Name(), stored, ok, needsRehash, err, wantRehash)
+			}
+			if ok, _, err := p.Verify("wrong", stored); ok || err != nil {
+				t.Errorf("%s: Verify(wrong) against %q = %v, %v, want false, nil", current.Name(), stored, ok, err)
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if p, err := New(""); err != nil || p.Name() != DefaultHasher {
+		t.Errorf(`New("") = %v, %v, want the default hasher`, p, err)
+	}
+	if _, err := New("md5"); err == nil || !strings.Contains(err.Error(), "md5") {
+		t.Errorf(`New("md5") error = %v, want unknown hasher`, err)
+	}
+	if _, err := (Plaintext{}).Hash("$2a$looks-hashed"); !errors.Is(err, ErrDollarPrefix) {
+		t.Errorf("plaintext of a value starting with '$': err = %v, want ErrDollarPrefix", err)
+	}
+}
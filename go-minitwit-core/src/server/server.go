@@ -0,0 +1,98 @@
+// Package server runs an http.Handler until the process is told to stop and
+// then drains the requests that are still in flight, so stopping a container
+// mid-experiment does not cut requests off.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type Server struct {
+	http *http.Server
+
+	inFlight atomic.Int64
+	served   atomic.Int64
+}
+
+// Summary describes a shutdown
+type Summary struct {
+	// Served counts the requests completed over the server's lifetime
+	Served int64
+	// InFlight were running when shutdown started
+	InFlight int64
+	// Abandoned were still running at the drain deadline and got their connection closed
+	Abandoned int64
+	// Drain is how long the shutdown took
+	Drain time.Duration
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("served %d requests, drained %d of %d in flight in %s (%d abandoned)",
+		s.Served, s.InFlight-s.Abandoned, s.InFlight, s.Drain.Round(time.Millisecond), s.Abandoned)
+}
+
+func New(addr string, handler http.Handler) *Server {
+	s := &Server{}
+	s.http = &http.Server{Addr: addr, Handler: s.track(handler)}
+	return s
+}
+
+// Run listens on the configured address, see Serve
+func (s *Server) Run(ctx context.Context, drainTimeout time.Duration) (Summary, error) {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return Summary{}, err
+	}
+	return s.Serve(ctx, ln, drainTimeout)
+}
+
+// Serve handles requests on ln until ctx is done, then stops accepting
+// connections and waits up to drainTimeout for in-flight requests. Requests
+// still running after that have their connections closed.
+func (s *Server) Serve(ctx context.Context, ln net.Listener, drainTimeout time.Duration) (Summary, error) {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.http.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		// the listener failed before anyone asked us to stop
+		return Summary{Served: s.served.Load()}, err
+	case <-ctx.Done():
+	}
+
+	start := time.Now()
+	summary := Summary{InFlight: s.inFlight.Load()}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := s.http.Shutdown(drainCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		summary.Abandoned = s.inFlight.Load()
+		err = s.http.Close()
+	}
+	<-errc // always http.ErrServerClosed once Shutdown or Close was called
+
+	summary.Served = s.served.Load()
+	summary.Drain = time.Since(start)
+	return summary, err
+}
+
+func (s *Server) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer func() {
+			s.inFlight.Add(-1)
+			s.served.Add(1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// slowServer starts a server whose handler blocks until release is closed
+func slowServer(t *testing.T, drainTimeout time.Duration) (url string, release chan struct{}, started chan struct{}, stop context.CancelFunc, done chan Summary) {
+	t.Helper()
+
+	release, started = make(chan struct{}), make(chan struct{}, 1)
+	srv := New("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		io.WriteString(w, "done")
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	done = make(chan Summary, 1)
+	go func() {
+		summary, err := srv.Serve(ctx, ln, drainTimeout)
+		if err != nil && err != http.ErrServerClosed {
+			t.Errorf("Serve: %v", err)
+		}
+		done <- summary
+	}()
+	return "http://" + ln.Addr().String(), release, started, stop, done
+}
+
+func TestDrainsInFlightRequests(t *testing.T) {
+	url, release, started, stop, done := slowServer(t, 5*time.Second)
+
+	respc := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			respc <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		respc <- string(body)
+	}()
+
+	<-started
+	stop()
+	time.Sleep(50 * time.Millisecond) // let Shutdown close the listener
+	if _, err := http.Get(url); err == nil {
+		t.Error("new connection accepted after shutdown started")
+	}
+	close(release)
+
+	if body := <-respc; body != "done" {
+		t.Errorf("in-flight request got %q, want done", body)
+	}
+	summary := <-done
+	if summary.InFlight != 1 || summary.Abandoned != 0 || summary.Served != 1 {
+		t.Errorf("summary = %+v, want 1 in flight, 0 abandoned, 1 served", summary)
+	}
+}
+
+func TestAbandonsAfterDeadline(t *testing.T) {
+	url, release, started, stop, done := slowServer(t, 50*time.Millisecond)
+	defer close(release)
+
+	go http.Get(url)
+	<-started
+	stop()
+
+	summary := <-done
+	if summary.InFlight != 1 || summary.Abandoned != 1 {
+		t.Errorf("summary = %+v, want 1 in flight and 1 abandoned", summary)
+	}
+}
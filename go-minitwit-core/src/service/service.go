@@ -0,0 +1,318 @@
+// Package service holds the Minitwit use cases shared by the web front-ends:
+// registering and deleting users, following other users, reading timelines
+// and moderating messages.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/models"
+	"go-minitwit-core/src/password"
+	"go-minitwit-core/src/store"
+	"log"
+	"strings"
+)
+
+var (
+	ErrUsernameTaken   = &ValidationError{"The username is already taken"}
+	ErrInvalidUsername = &ValidationError{"Invalid username"}
+	ErrInvalidPassword = &ValidationError{"Invalid password"}
+	// ErrDollarPassword rejects what the plaintext hasher cannot store
+	ErrDollarPassword = &ValidationError{"The password must not start with '$'"}
+	// ErrUnknownUser is store.ErrUserNotFound, errors.Is matches the store's errors
+	ErrUnknownUser = store.ErrUserNotFound
+	// ErrNotModerator is returned when a user who is not a moderator flags messages
+	ErrNotModerator = errors.New("moderator rights required")
+)
+
+// ValidationError carries the message shown to the user when a form is rejected.
+type ValidationError struct {
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
+
+// Service runs the use cases against an injected store
+type Service struct {
+	store      store.Store
+	passwords  *password.Policy
+	moderators map[int]bool
+}
+
+func New(s store.Store, passwords *password.Policy) *Service {
+	return &Service{store: s, passwords: passwords, moderators: map[int]bool{}}
+}
+
+// SetModerators sets the users that may flag and unflag messages. They are
+// given by id, a username would pass the rights on to whoever registers it
+// after the moderator's account is deleted
+func (s *Service) SetModerators(userIDs []int) {
+	s.moderators = map[int]bool{}
+	for _, id := range userIDs {
+		s.moderators[id] = true
+	}
+}
+
+// IsModerator reports whether userID may flag and unflag messages
+func (s *Service) IsModerator(userID int) bool {
+	return s.moderators[userID]
+}
+
+// ValidateRegistration checks the register form in the same order as the reference Minitwit
+func ValidateRegistration(userName string, email string, password string, password2 string) error {
+	if userName == "" {
+		return &ValidationError{"You have to enter a username"}
+	} else if email == "" || !strings.Contains(email, "@") {
+		return &ValidationError{"You have to enter a valid email address"}
+	} else if password == "" {
+		return &ValidationError{"You have to enter a password"}
+	} else if password != password2 {
+		return &ValidationError{"The two passwords do not match"}
+	}
+	return nil
+}
+
+// Register creates a new user unless the username is already in use. The
+// store decides that in the insert itself, a separate lookup first would race
+// with concurrent registrations of the same name.
+func (s *Service) Register(userName string, email string, pwd string) error {
+	pwHash, err := s.passwords.Hash(pwd)
+	if errors.Is(err, password.ErrDollarPrefix) {
+		return ErrDollarPassword
+	}
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+	err = s.store.RegisterUser(userName, email, pwHash)
+	if errors.Is(err, store.ErrDuplicateUsername) {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+// Login checks the credentials and returns the user. Passwords stored in an
+// older format (e.g. plaintext) are rehashed with the current hasher.
+func (s *Service) Login(userName string, pwd string) (models.Users, error) {
+	user, err := s.store.GetUserByUsername(userName)
+	if errors.Is(err, store.ErrUserNotFound) {
+		return user, ErrInvalidUsername
+	}
+	if err != nil {
+		return user, err
+	}
+
+	ok, needsRehash, err := s.passwords.Verify(pwd, user.Pwd)
+	if err != nil {
+		return user, fmt.Errorf("verifying password of %s: %w", userName, err)
+	}
+	if !ok {
+		return user, ErrInvalidPassword
+	}
+
+	if needsRehash {
+		// the login itself succeeded, a failed upgrade is retried next time
+		if pwHash, err := s.passwords.Hash(pwd); err != nil {
+			log.Printf("rehashing password of %s: %v", userName, err)
+		} else if err := s.store.UpdatePassword(user.UserID, pwHash); err != nil {
+			log.Printf("storing rehashed password of %s: %v", userName, err)
+		} else {
+			user.Pwd = pwHash
+		}
+	}
+	return user, nil
+}
+
+// DeleteAccount removes userID together with their messages and follower
+// relations, once pwd confirms it is really them
+func (s *Service) DeleteAccount(userID int, pwd string) error {
+	userName, err := s.store.GetUserNameByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Login(userName, pwd); err != nil {
+		return err
+	}
+	return s.store.DeleteUser(userID)
+}
+
+// DeleteOwn is the simulator's delete: it removes message messageID of
+// userName or, for a messageID of 0, the whole account like DeleteAccount.
+// Either way pwd has to be the user's password, the simulator credentials
+// alone do not let a caller delete other people's data.
+func (s *Service) DeleteOwn(userName string, pwd string, messageID int) error {
+	user, err := s.Login(userName, pwd)
+	if errors.Is(err, ErrInvalidUsername) {
+		return ErrUnknownUser
+	}
+	if err != nil {
+		return err
+	}
+	if messageID == 0 {
+		return s.store.DeleteUser(user.UserID)
+	}
+	return s.store.DeleteMessage(messageID, user.UserID)
+}
+
+// Follow makes userID follow the user called profileUserName
+func (s *Service) Follow(userID int, profileUserName string) error {
+	profileUserID, err := s.store.GetUserIDByUsername(profileUserName)
+	if err != nil {
+		return err
+	}
+	return s.store.FollowUser(userID, profileUserID)
+}
+
+// Unfollow removes userID as a follower of the user called profileUserName
+func (s *Service) Unfollow(userID int, profileUserName string) error {
+	profileUserID, err := s.store.GetUserIDByUsername(profileUserName)
+	if err != nil {
+		return err
+	}
+	return s.store.UnfollowUser(userID, profileUserID)
+}
+
+// PublicTimeline returns a page of the unflagged messages of all users, newest first
+func (s *Service) PublicTimeline(page store.Page) (TimelinePage, error) {
+	return paginate(page, true, s.store.GetPublicMessages)
+}
+
+// UserTimeline returns the profile user together with a page of their messages, oldest first
+func (s *Service) UserTimeline(profileUserName string, page store.Page) (models.Users, TimelinePage, error) {
+	profileUser, err := s.store.GetUserByUsername(profileUserName)
+	if err != nil {
+		return profileUser, TimelinePage{}, err
+	}
+
+	timeline, err := s.UserMessages(profileUser.UserID, page)
+	return profileUser, timeline, err
+}
+
+// UserMessages is UserTimeline for callers that already resolved the user
+func (s *Service) UserMessages(userID int, page store.Page) (TimelinePage, error) {
+	return paginate(page, false, func(page store.Page) ([]models.MessageUser, error) {
+		return s.store.GetUserMessages(userID, page)
+	})
+}
+
+// MyTimeline returns a page of the messages of userID and of everyone they
+// follow, newest first
+func (s *Service) MyTimeline(userID int, page store.Page) (TimelinePage, error) {
+	return paginate(page, true, func(page store.Page) ([]models.MessageUser, error) {
+		return s.store.GetMyMessages(userID, page)
+	})
+}
+
+// TimelinePage is one page of a timeline. Older and Newer are the values for
+// ?before= and ?after= that lead to the neighbouring pages, empty when there
+// is nothing to show in that direction.
+type TimelinePage struct {
+	Messages []models.MessageUser
+	Older    string
+	Newer    string
+}
+
+// paginate fetches one message more than asked for to find out whether the
+// page has a neighbour on the side away from the cursor
+func paginate(page store.Page, newestFirst bool, fetch func(store.Page) ([]models.MessageUser, error)) (TimelinePage, error) {
+	if page.Limit < 0 {
+		messages, err := fetch(page)
+		return TimelinePage{Messages: messages}, err
+	}
+
+	probe := page
+	probe.Limit++
+	messages, err := fetch(probe)
+	if err != nil {
+		return TimelinePage{}, err
+	}
+
+	more := len(messages) > page.Limit
+	if more {
+		// the extra message is the one furthest from the cursor
+		if (page.Before != nil && !newestFirst) || (page.After != nil && newestFirst) {
+			messages = messages[1:]
+		} else {
+			messages = messages[:page.Limit]
+		}
+	}
+
+	timeline := TimelinePage{Messages: messages}
+	if len(messages) == 0 {
+		return timeline, nil
+	}
+
+	oldest, newest := messages[0], messages[len(messages)-1]
+	if newestFirst {
+		oldest, newest = newest, oldest
+	}
+
+	var hasOlder, hasNewer bool
+	switch {
+	case page.Before != nil:
+		hasOlder, hasNewer = more, true
+	case page.After != nil:
+		hasOlder, hasNewer = true, more
+	default:
+		// the first page starts at the newest or the oldest end of the timeline
+		hasOlder, hasNewer = more && newestFirst, more && !newestFirst
+	}
+	if hasOlder {
+		timeline.Older = cursorOf(oldest).String()
+	}
+	if hasNewer {
+		timeline.Newer = cursorOf(newest).String()
+	}
+	return timeline, nil
+}
+
+func cursorOf(m models.MessageUser) store.Cursor {
+	return store.Cursor{PubDate: m.PubDate, MessageID: m.MessageID}
+}
+
+// AuthenticateModerator checks the Authorization header of a moderation API
+// request, Basic auth with the moderator's own username and password
+func (s *Service) AuthenticateModerator(header string) (models.Users, error) {
+	userName, pwd, err := apiauth.ParseBasic(header)
+	if err != nil {
+		return models.Users{}, err
+	}
+	user, err := s.Login(userName, pwd)
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return user, apiauth.ErrUnauthorized
+	}
+	if err != nil {
+		return user, err
+	}
+	if !s.IsModerator(user.UserID) {
+		return user, ErrNotModerator
+	}
+	return user, nil
+}
+
+// SetFlagged flags or unflags messageID on behalf of moderatorID
+func (s *Service) SetFlagged(moderatorID int, messageID int, flagged bool) error {
+	if err := s.checkModerator(moderatorID); err != nil {
+		return err
+	}
+	return s.store.SetFlagged(messageID, flagged, moderatorID)
+}
+
+// FlaggedMessages lists up to limit flagged messages to moderatorID, the most
+// recently flagged first
+func (s *Service) FlaggedMessages(moderatorID int, limit int) ([]models.FlaggedMessage, error) {
+	if err := s.checkModerator(moderatorID); err != nil {
+		return nil, err
+	}
+	return s.store.GetFlaggedMessages(limit)
+}
+
+func (s *Service) checkModerator(userID int) error {
+	if !s.IsModerator(userID) {
+		return ErrNotModerator
+	}
+	return nil
+}
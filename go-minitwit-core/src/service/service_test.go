@@ -0,0 +1,230 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"go-minitwit-core/src/apiauth"
+	"go-minitwit-core/src/memstore"
+	"go-minitwit-core/src/password"
+	"go-minitwit-core/src/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, messages int) (*Service, *memstore.Store) {
+	t.Helper()
+
+	s := memstore.New()
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	_ = s.RegisterUser("aa", "a@a.a", "pwd")
+	for i := 1; i <= messages; i++ {
+		_ = s.AddMessage("m"+string(rune('0'+i)), 1)
+	}
+	return New(s, password.NewPolicy(password.Plaintext{})), s
+}
+
+func texts(p TimelinePage) string {
+	var t []string
+	for _, m := range p.Messages {
+		t = append(t, m.Text)
+	}
+	return strings.Join(t, " ")
+}
+
+// walk follows one link direction from the first page and records every page
+func walk(t *testing.T, first TimelinePage, next func(TimelinePage) (TimelinePage, bool)) []string {
+	t.Helper()
+
+	pages := []string{texts(first)}
+	for p, ok := next(first); ok; p, ok = next(p) {
+		pages = append(pages, texts(p))
+		if len(pages) > 10 {
+			t.Fatal("pagination does not terminate")
+		}
+	}
+	return pages
+}
+
+func TestPublicTimelinePaging(t *testing.T) {
+	svc, _ := newTestService(t, 5)
+
+	first, err := svc.PublicTimeline(store.FirstPage(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Newer != "" {
+		t.Errorf("first page has a newer link %q", first.Newer)
+	}
+
+	var last TimelinePage
+	older := walk(t, first, func(p TimelinePage) (TimelinePage, bool) {
+		if p.Older == "" {
+			last = p
+			return p, false
+		}
+		page, err := store.ParsePage(p.Older, "", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := svc.PublicTimeline(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return next, true
+	})
+	if got := strings.Join(older, " | "); got != "m5 m4 | m3 m2 | m1" {
+		t.Errorf("older pages = %q", got)
+	}
+
+	newer := walk(t, last, func(p TimelinePage) (TimelinePage, bool) {
+		if p.Newer == "" {
+			return p, false
+		}
+		page, _ := store.ParsePage("", p.Newer, 2)
+		next, _ := svc.PublicTimeline(page)
+		return next, true
+	})
+	if got := strings.Join(newer, " | "); got != "m1 | m3 m2 | m5 m4" {
+		t.Errorf("newer pages = %q", got)
+	}
+}
+
+func TestUserTimelinePaging(t *testing.T) {
+	svc, _ := newTestService(t, 3)
+
+	_, first, err := svc.UserTimeline("aa", store.FirstPage(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts(first) != "m1 m2" || first.Older != "" || first.Newer == "" {
+		t.Fatalf("first page = %q older=%q newer=%q, want oldest first with only a newer link", texts(first), first.Older, first.Newer)
+	}
+
+	page, _ := store.ParsePage("", first.Newer, 2)
+	_, next, _ := svc.UserTimeline("aa", page)
+	if texts(next) != "m3" || next.Newer != "" || next.Older == "" {
+		t.Errorf("second page = %q older=%q newer=%q", texts(next), next.Older, next.Newer)
+	}
+
+	if _, _, err := svc.UserTimeline("nobody", store.FirstPage(2)); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("unknown user: err = %v, want ErrUnknownUser", err)
+	}
+}
+
+func TestParsePageRejects(t *testing.T) {
+	for _, q := range [][2]string{{"x", ""}, {"", "1.x"}, {"1.1", "1.1"}} {
+		if _, err := store.ParsePage(q[0], q[1], 10); err == nil {
+			t.Errorf("ParsePage(%q, %q) accepted", q[0], q[1])
+		}
+	}
+}
+
+func TestModeration(t *testing.T) {
+	svc, s := newTestService(t, 2)
+	_ = s.RegisterUser("mod", "m@m.m", "pwd")
+	const aa, mod = 1, 2
+	svc.SetModerators([]int{mod})
+
+	if err := svc.SetFlagged(aa, 1, true); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("SetFlagged by aa: err = %v, want ErrNotModerator", err)
+	}
+	if err := svc.SetFlagged(mod, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if flagged, err := svc.FlaggedMessages(mod, 10); err != nil || len(flagged) != 1 || flagged[0].FlaggedBy != "mod" {
+		t.Errorf("FlaggedMessages = %+v, %v, want m1 flagged by mod", flagged, err)
+	}
+	if _, err := svc.FlaggedMessages(aa, 10); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("FlaggedMessages for aa: err = %v, want ErrNotModerator", err)
+	}
+
+	basic := func(user, pwd string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pwd))
+	}
+	for _, tt := range []struct {
+		header string
+		want   error
+	}{
+		{basic("mod", "pwd"), nil},
+		{basic("mod", "wrong"), apiauth.ErrUnauthorized},
+		{basic("nobody", "pwd"), apiauth.ErrUnauthorized},
+		{basic("aa", "pwd"), ErrNotModerator},
+		{"", apiauth.ErrMissing},
+	} {
+		if _, err := svc.AuthenticateModerator(tt.header); !errors.Is(err, tt.want) {
+			t.Errorf("AuthenticateModerator(%q) err = %v, want %v", tt.header, err, tt.want)
+		}
+	}
+
+	// a new account under the name of a deleted moderator is not a moderator
+	if err := svc.DeleteAccount(mod, "pwd"); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.RegisterUser("mod", "m@m.m", "pwd")
+	if _, err := svc.AuthenticateModerator(basic("mod", "pwd")); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("re-registered mod: err = %v, want ErrNotModerator", err)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	svc, s := newTestService(t, 2)
+	const aa = 1
+
+	if err := svc.DeleteAccount(aa, "wrong"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("wrong password: err = %v, want ErrInvalidPassword", err)
+	}
+	if err := svc.DeleteAccount(aa, "pwd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUserNameByUserID(aa); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("after delete: err = %v, want ErrUnknownUser", err)
+	}
+	if err := svc.DeleteAccount(aa, "pwd"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("deleting twice: err = %v, want ErrUnknownUser", err)
+	}
+}
+
+func TestDeleteOwn(t *testing.T) {
+	svc, s := newTestService(t, 1)
+	_ = s.RegisterUser("bb", "b@b.b", "pwd")
+	_ = s.AddMessage("by bb", 2)
+
+	tests := []struct {
+		userName  string
+		pwd       string
+		messageID int
+		want      error
+	}{
+		{"aa", "wrong", 1, ErrInvalidPassword},
+		{"aa", "wrong", 0, ErrInvalidPassword},
+		{"nobody", "pwd", 0, ErrUnknownUser},
+		{"aa", "pwd", 2, store.ErrMessageNotFound}, // written by bb
+		{"bb", "pwd", 2, nil},
+		{"aa", "pwd", 0, nil},
+	}
+	for _, tt := range tests {
+		if err := svc.DeleteOwn(tt.userName, tt.pwd, tt.messageID); !errors.Is(err, tt.want) {
+			t.Errorf("DeleteOwn(%s, %s, %d) = %v, want %v", tt.userName, tt.pwd, tt.messageID, err, tt.want)
+		}
+	}
+	if _, err := s.GetUserIDByUsername("aa"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("aa: err = %v, want ErrUnknownUser", err)
+	}
+	if _, err := s.GetUserIDByUsername("bb"); err != nil {
+		t.Errorf("bb: err = %v, want the account kept", err)
+	}
+}
+
+func TestRegisterDollarPassword(t *testing.T) {
+	svc, _ := newTestService(t, 0)
+	err := svc.Register("bb", "b@b.b", "$2a$secret")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("Register with a '$' password under plaintext: err = %v, want a ValidationError", err)
+	}
+}
@@ -0,0 +1,56 @@
+package sessionstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionRow is a row of http_sessions
+type sessionRow struct {
+	ID        string `gorm:"primaryKey"`
+	Data      []byte
+	ExpiresOn time.Time `gorm:"index"`
+}
+
+func (sessionRow) TableName() string {
+	return "http_sessions"
+}
+
+type gormBackend struct {
+	db *gorm.DB
+}
+
+// newGormBackend expects http_sessions to exist, migration 0003 creates it
+func newGormBackend(db *gorm.DB) (*gormBackend, error) {
+	if !db.Migrator().HasTable(&sessionRow{}) {
+		return nil, fmt.Errorf("table http_sessions does not exist, run the migrations first")
+	}
+	return &gormBackend{db: db}, nil
+}
+
+func (g *gormBackend) load(id string, now time.Time) ([]byte, bool, error) {
+	var row sessionRow
+	err := g.db.Where("id = ? AND expires_on > ?", id, now).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return row.Data, true, nil
+}
+
+func (g *gormBackend) save(id string, data []byte, expires time.Time) error {
+	return g.db.Save(&sessionRow{ID: id, Data: data, ExpiresOn: expires}).Error
+}
+
+func (g *gormBackend) delete(id string) error {
+	return g.db.Delete(&sessionRow{ID: id}).Error
+}
+
+func (g *gormBackend) sweep(now time.Time) error {
+	return g.db.Where("expires_on <= ?", now).Delete(&sessionRow{}).Error
+}
@@ -0,0 +1,195 @@
+package sessionstore
+
+import (
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// backend keeps serialized session values by session id
+type backend interface {
+	load(id string, now time.Time) (data []byte, ok bool, err error)
+	save(id string, data []byte, expires time.Time) error
+	delete(id string) error
+	// sweep drops the sessions that expired before now
+	sweep(now time.Time) error
+}
+
+// expired rows are swept every sweepEvery saves instead of on a timer
+const sweepEvery = 1000
+
+// defaultTTL applies to sessions whose cookie has no Max-Age
+const defaultTTL = 86400 * 30 * time.Second
+
+// serverStore keeps the values in a backend, the cookie only holds the signed session id
+type serverStore struct {
+	backend    backend
+	codecs     []securecookie.Codec
+	serializer securecookie.GobEncoder
+
+	mu      sync.RWMutex
+	options *sessions.Options
+	saves   int
+}
+
+func newServerStore(b backend, keyPairs [][]byte) *serverStore {
+	s := &serverStore{
+		backend: b,
+		codecs:  securecookie.CodecsFromPairs(keyPairs...),
+	}
+	s.SetOptions(&sessions.Options{Path: "/", MaxAge: 86400 * 30})
+	return s
+}
+
+func (s *serverStore) SetOptions(opts *sessions.Options) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.options = opts
+	for _, c := range s.codecs {
+		if codec, ok := c.(*securecookie.SecureCookie); ok {
+			codec.MaxAge(opts.MaxAge)
+		}
+	}
+}
+
+func (s *serverStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request cookie. A missing, forged or
+// expired id silently starts a new session, like a fresh visitor.
+func (s *serverStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	s.mu.RLock()
+	opts := *s.options
+	s.mu.RUnlock()
+
+	session := sessions.NewSession(s, name)
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, c.Value, &id, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	data, ok, err := s.backend.load(id, time.Now())
+	if err != nil || !ok {
+		return session, err
+	}
+	if err := s.serializer.Deserialize(data, &session.Values); err != nil {
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *serverStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.backend.delete(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+	data, err := s.serializer.Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+	ttl := defaultTTL
+	if session.Options.MaxAge > 0 {
+		ttl = time.Duration(session.Options.MaxAge) * time.Second
+	}
+	if err := s.backend.save(session.ID, data, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	s.maybeSweep()
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func (s *serverStore) maybeSweep() {
+	s.mu.Lock()
+	s.saves++
+	due := s.saves%sweepEvery == 0
+	s.mu.Unlock()
+
+	if due {
+		// best effort, expired sessions are never loaded anyway
+		_ = s.backend.sweep(time.Now())
+	}
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+type memoryBackend struct {
+	mu       sync.RWMutex
+	sessions map[string]memoryEntry
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{sessions: map[string]memoryEntry{}}
+}
+
+func (m *memoryBackend) load(id string, now time.Time) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.sessions[id]
+	if !ok || !now.Before(e.expires) {
+		return nil, false, nil
+	}
+	return e.data, true, nil
+}
+
+func (m *memoryBackend) save(id string, data []byte, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[id] = memoryEntry{data: data, expires: expires}
+	return nil
+}
+
+func (m *memoryBackend) delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memoryBackend) sweep(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, e := range m.sessions {
+		if !now.Before(e.expires) {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package sessionstore builds the gorilla sessions.Store used for the UI login.
+//
+// The backend (SESSION_BACKEND, see package config) picks where session data lives:
+//   - cookie (default): signed, optionally encrypted, in the cookie itself
+//   - memory: in the process, the cookie only carries a signed session id
+//   - postgres: in the http_sessions table of the application database
+//
+// The keys (SESSION_KEYS) are a list, newest first. Each entry is
+// "hashKey" or "hashKey:blockKey"; a block key (16, 24 or 32 bytes) turns on
+// AES encryption. New cookies are signed with the first entry, the others are
+// only used to read cookies issued before a rotation.
+package sessionstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"gorm.io/gorm"
+)
+
+const (
+	BackendCookie   = "cookie"
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+// Store is a sessions.Store whose default cookie options can be replaced,
+// which is what gin-contrib/sessions needs from a store
+type Store interface {
+	sessions.Store
+	SetOptions(opts *sessions.Options)
+}
+
+type Config struct {
+	Backend string
+	// Keys are "hashKey[:blockKey]" entries, newest first
+	Keys []string
+}
+
+// New builds the store for cfg. db is only used by the postgres backend.
+func New(cfg Config, db *gorm.DB) (Store, error) {
+	keyPairs, err := KeyPairs(cfg.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Backend {
+	case "", BackendCookie:
+		return &cookieStore{sessions.NewCookieStore(keyPairs...)}, nil
+	case BackendMemory:
+		return newServerStore(newMemoryBackend(), keyPairs), nil
+	case BackendPostgres:
+		if db == nil {
+			return nil, errors.New("session backend postgres needs a database connection")
+		}
+		backend, err := newGormBackend(db)
+		if err != nil {
+			return nil, err
+		}
+		return newServerStore(backend, keyPairs), nil
+	default:
+		return nil, fmt.Errorf("unknown session backend %q (want cookie, memory or postgres)", cfg.Backend)
+	}
+}
+
+// KeyPairs turns "hashKey[:blockKey]" entries into the hash/block pairs securecookie expects
+func KeyPairs(keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no session keys configured")
+	}
+
+	pairs := make([][]byte, 0, 2*len(keys))
+	for i, k := range keys {
+		hashKey, blockKey, _ := strings.Cut(k, ":")
+		if hashKey == "" {
+			return nil, fmt.Errorf("session key %d: empty hash key", i+1)
+		}
+		switch len(blockKey) {
+		case 0, 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("session key %d: block key must be 16, 24 or 32 bytes, got %d", i+1, len(blockKey))
+		}
+
+		var block []byte
+		if blockKey != "" {
+			block = []byte(blockKey)
+		}
+		pairs = append(pairs, []byte(hashKey), block)
+	}
+	return pairs, nil
+}
+
+type cookieStore struct {
+	*sessions.CookieStore
+}
+
+func (s *cookieStore) SetOptions(opts *sessions.Options) {
+	s.Options = opts
+	s.MaxAge(opts.MaxAge)
+}
@@ -0,0 +1,100 @@
+package sessionstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// roundTrip saves value with one store and reads it back with another,
+// through the cookie the first one set
+func roundTrip(t *testing.T, write Store, read Store, value string) (string, bool) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	session, err := write.Get(req, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["v"] = value
+	if err := session.Save(req, w); err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	session, _ = read.Get(req, "s")
+	got, ok := session.Values["v"].(string)
+	return got, ok
+}
+
+func TestBackends(t *testing.T) {
+	for _, backend := range []string{BackendCookie, BackendMemory} {
+		s, err := New(Config{Backend: backend, Keys: []string{"hash-key:0123456789abcdef"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := roundTrip(t, s, s, "hello"); !ok || got != "hello" {
+			t.Errorf("%s: read back %q, %v", backend, got, ok)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	for _, backend := range []string{BackendCookie, BackendMemory} {
+		old, _ := New(Config{Backend: backend, Keys: []string{"old"}}, nil)
+		rotated, _ := New(Config{Backend: backend, Keys: []string{"new", "old"}}, nil)
+		dropped, _ := New(Config{Backend: backend, Keys: []string{"new"}}, nil)
+
+		if backend == BackendMemory {
+			// server-side stores share the session data, only the keys differ
+			shared := old.(*serverStore).backend
+			rotated.(*serverStore).backend = shared
+			dropped.(*serverStore).backend = shared
+		}
+
+		if got, ok := roundTrip(t, old, rotated, "x"); !ok || got != "x" {
+			t.Errorf("%s: cookie signed with the old key not accepted after rotation", backend)
+		}
+		if _, ok := roundTrip(t, old, dropped, "x"); ok {
+			t.Errorf("%s: cookie signed with a dropped key still accepted", backend)
+		}
+	}
+}
+
+func TestMemoryLogout(t *testing.T) {
+	s, _ := New(Config{Backend: BackendMemory, Keys: []string{"k"}}, nil)
+	mem := s.(*serverStore).backend.(*memoryBackend)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, _ := s.Get(req, "s")
+	session.Values["user_id"] = 1
+	_ = session.Save(req, httptest.NewRecorder())
+	if len(mem.sessions) != 1 {
+		t.Fatalf("sessions = %d, want 1", len(mem.sessions))
+	}
+
+	session.Options.MaxAge = -1
+	_ = session.Save(req, httptest.NewRecorder())
+	if len(mem.sessions) != 0 {
+		t.Errorf("sessions after MaxAge -1 = %d, want 0", len(mem.sessions))
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	bad := []Config{
+		{Backend: "redis", Keys: []string{"k"}},
+		{Backend: BackendPostgres, Keys: []string{"k"}},
+		{Keys: nil},
+		{Keys: []string{":0123456789abcdef"}},
+		{Keys: []string{"k:short"}},
+	}
+	for _, cfg := range bad {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("New(%+v) accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("invalid limit")
+)
+
+// Cursor is a position in a timeline. Messages are ordered by (pub_date,
+// message_id), so a cursor is unambiguous even when messages share a timestamp.
+type Cursor struct {
+	PubDate   time.Time
+	MessageID int
+}
+
+// String encodes the cursor as "<unix nanoseconds>.<message id>" for ?before= and ?after=
+func (c Cursor) String() string {
+	return fmt.Sprintf("%d.%d", c.PubDate.UnixNano(), c.MessageID)
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	nanos, id, ok := strings.Cut(s, ".")
+	if !ok {
+		return Cursor{}, fmt.Errorf("%w %q", ErrInvalidCursor, s)
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w %q", ErrInvalidCursor, s)
+	}
+	messageID, err := strconv.Atoi(id)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w %q", ErrInvalidCursor, s)
+	}
+	return Cursor{PubDate: time.Unix(0, n).UTC(), MessageID: messageID}, nil
+}
+
+// Page selects a window of a timeline: at most Limit messages (negative means
+// no limit) directly before or after a cursor, or the first page when both are nil.
+// Before and After are in time, whatever order the timeline is shown in.
+type Page struct {
+	Limit  int
+	Before *Cursor
+	After  *Cursor
+}
+
+// FirstPage returns the first limit messages of a timeline
+func FirstPage(limit int) Page {
+	return Page{Limit: limit}
+}
+
+// ParseLimit reads the ?no= page size of the API list endpoints: empty means
+// def, anything but a positive number is an error and values above max are capped.
+func ParseLimit(no string, def int, max int) (int, error) {
+	if no == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(no)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%w %q, want a positive number", ErrInvalidLimit, no)
+	}
+	return min(n, max), nil
+}
+
+// ParsePage builds a page from the ?before= and ?after= query values
+func ParsePage(before string, after string, limit int) (Page, error) {
+	page := Page{Limit: limit}
+	if before != "" && after != "" {
+		return page, fmt.Errorf("%w: before and after are mutually exclusive", ErrInvalidCursor)
+	}
+	if before != "" {
+		c, err := ParseCursor(before)
+		if err != nil {
+			return page, err
+		}
+		page.Before = &c
+	}
+	if after != "" {
+		c, err := ParseCursor(after)
+		if err != nil {
+			return page, err
+		}
+		page.After = &c
+	}
+	return page, nil
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		no   string
+		want int
+		err  bool
+	}{
+		{"", 100, false},
+		{"5", 5, false},
+		{"1000", 1000, false},
+		{"5000", 1000, false},
+		{"0", 0, true},
+		{"-3", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.no, 100, 1000)
+		if tt.err != (err != nil) || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %d, %v, want %d", tt.no, got, err, tt.want)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("ParseLimit(%q) error = %v, want ErrInvalidLimit", tt.no, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package store defines the persistence operations Minitwit needs, so the
+// handlers and services do not depend on a concrete database.
+package store
+
+import (
+	"errors"
+	"go-minitwit-core/src/models"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned by the user lookups for names and IDs that
+	// do not exist, and by FollowUser when either side does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrDuplicateUsername is returned by RegisterUser when the name is taken
+	ErrDuplicateUsername = errors.New("username is already taken")
+	// ErrMessageNotFound is returned for message IDs that do not exist
+	ErrMessageNotFound = errors.New("message not found")
+)
+
+// Store is implemented by every Minitwit backend (see db.GormStore)
+type Store interface {
+	// users, the lookups fail with ErrUserNotFound for unknown users
+	GetUserNameByUserID(userID int) (string, error)
+	GetUserIDByUsername(userName string) (int, error)
+	GetUserByUsername(userName string) (models.Users, error)
+	RegisterUser(userName string, email string, pwHash string) error
+	UpdatePassword(userID int, pwHash string) error
+	// DeleteUser removes the user together with their messages, their follower
+	// rows in both directions and the moderation log entries that refer to them
+	DeleteUser(userID int) error
+
+	// messages, the public and home timelines are newest first and a user's
+	// own timeline is oldest first; page picks the window (see Page)
+	GetPublicMessages(page Page) ([]models.MessageUser, error)
+	GetMyMessages(userID int, page Page) ([]models.MessageUser, error)
+	GetUserMessages(pUserId int, page Page) ([]models.MessageUser, error)
+	AddMessage(text string, authorID int) error // ErrUserNotFound for an unknown author
+	// DeleteMessage fails with ErrMessageNotFound unless authorID wrote messageID
+	DeleteMessage(messageID int, authorID int) error
+
+	// followers
+	GetFollowing(userID int, limit int) ([]models.Users, error)
+	FollowUser(userID int, profileUserID int) error // ErrUserNotFound for an unknown user
+	UnfollowUser(userID int, profileUserID int) error
+
+	// moderation, every change of messages.flagged is recorded in the moderation
+	// log; flagged messages are listed most recently flagged first
+	SetFlagged(messageID int, flagged bool, moderatorID int) error
+	GetFlaggedMessages(limit int) ([]models.FlaggedMessage, error)
+
+	// simulator bookkeeping, latest is -1 before the first command
+	GetLatest() (int, error)
+	UpdateLatest(commandID int) error
+
+	// the command log (see commandlog). ClaimCommand inserts cmd as running
+	// unless its id is taken, a running claim created before staleBefore is
+	// taken over; otherwise it returns the stored command and false.
+	// CompleteCommand stores the outcome of a claimed command and, with latest,
+	// makes it the latest one in the same transaction. ReleaseCommand drops a
+	// running claim so that a retry runs the command again.
+	ClaimCommand(cmd models.Command, staleBefore time.Time) (models.Command, bool, error)
+	CompleteCommand(cmd models.Command, latest bool) error
+	ReleaseCommand(commandID int) error
+}